@@ -0,0 +1,11 @@
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupName is the API group for catalog.cattle.io resources.
+const GroupName = "catalog.cattle.io"
+
+// SchemeGroupVersion is the group/version used to register these types.
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1"}
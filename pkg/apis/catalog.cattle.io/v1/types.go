@@ -0,0 +1,130 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterRepo represents a Helm chart repository (or git repository of
+// charts/templates) that Rancher makes available cluster wide.
+type ClusterRepo struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RepoSpec   `json:"spec,omitempty"`
+	Status RepoStatus `json:"status,omitempty"`
+}
+
+// RepoSpec describes where and how a ClusterRepo's contents should be
+// fetched. Exactly one of GitRepo or URL is expected to be set.
+type RepoSpec struct {
+	// CABundle is a PEM encoded CA bundle used to validate the repository's
+	// TLS certificate, for either Git or HTTP(S)/OCI remotes.
+	CABundle []byte `json:"caBundle,omitempty"`
+	// ClientSecret is a reference to a Secret containing credentials used to
+	// authenticate against the repository (basic auth, SSH, or a
+	// .dockerconfigjson pull secret for OCI registries).
+	ClientSecret *corev1.SecretReference `json:"clientSecret,omitempty"`
+	// InsecureSkipTLSverify disables TLS certificate verification.
+	InsecureSkipTLSverify bool `json:"insecureSkipTLSVerify,omitempty"`
+	// DisableSameOriginCheck disables the same origin check for Helm index
+	// downloads served from http(s) URLs.
+	DisableSameOriginCheck bool `json:"disableSameOriginCheck,omitempty"`
+	// ForceUpdate, when set to a time after the last download, triggers an
+	// immediate refresh regardless of the usual refresh cadence.
+	ForceUpdate *metav1.Time `json:"forceUpdate,omitempty"`
+
+	// GitRepo is the URL of a git repository containing Helm charts or
+	// cluster template definitions.
+	GitRepo string `json:"gitRepo,omitempty"`
+	// GitBranch is the branch of GitRepo to track.
+	GitBranch string `json:"gitBranch,omitempty"`
+
+	// URL is either an http(s) URL pointing at a Helm repository index, or
+	// an oci:// URL pointing at an OCI registry repository holding Helm
+	// chart artifacts.
+	URL string `json:"url,omitempty"`
+
+	// Verification configures provenance (.prov) signature checking for
+	// charts discovered in this repository.
+	Verification *Verification `json:"verification,omitempty"`
+
+	// RefreshInterval overrides the default refresh cadence for this repo.
+	// Mutually exclusive with RefreshSchedule; RefreshSchedule takes
+	// precedence if both are set.
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+	// RefreshSchedule is a cron expression overriding the default refresh
+	// cadence for this repo, e.g. "0 */6 * * *" to refresh every 6 hours.
+	RefreshSchedule string `json:"refreshSchedule,omitempty"`
+}
+
+// VerifyMode controls how a failed or missing chart provenance signature is
+// handled.
+type VerifyMode string
+
+const (
+	// VerifyModeOff disables provenance verification entirely.
+	VerifyModeOff VerifyMode = "off"
+	// VerifyModeIfPresent verifies a chart's .prov file when one exists and
+	// records its digest, but does not fail the repo when no .prov exists.
+	VerifyModeIfPresent VerifyMode = "if-present"
+	// VerifyModeRequired fails the repo's Downloaded condition for any
+	// chart missing a valid .prov signature.
+	VerifyModeRequired VerifyMode = "required"
+)
+
+// Verification references the GPG keyring used to validate chart provenance
+// signatures for a ClusterRepo.
+type Verification struct {
+	// Keyring references a Secret containing an ASCII-armored GPG public
+	// keyring under the key "keyring".
+	Keyring *corev1.SecretReference `json:"keyring,omitempty"`
+	// VerifyMode controls enforcement; defaults to VerifyModeOff.
+	VerifyMode VerifyMode `json:"verifyMode,omitempty"`
+}
+
+// RepoStatus records the last observed state of a ClusterRepo's contents.
+type RepoStatus struct {
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// URL is the RepoSpec.GitRepo or RepoSpec.URL value that produced this
+	// status, recorded verbatim so that shouldRefresh can detect spec
+	// changes.
+	URL    string `json:"url,omitempty"`
+	Branch string `json:"branch,omitempty"`
+	Commit string `json:"commit,omitempty"`
+
+	DownloadTime metav1.Time `json:"downloadTime,omitempty"`
+
+	IndexConfigMapName            string `json:"indexConfigMapName,omitempty"`
+	IndexConfigMapNamespace       string `json:"indexConfigMapNamespace,omitempty"`
+	IndexConfigMapResourceVersion string `json:"indexConfigMapResourceVersion,omitempty"`
+
+	// IndexDigest and IndexSize identify the chart index in the
+	// content-addressed index store (see pkg/catalogv2/index) in place of
+	// the IndexConfigMap fields above, when that store is in use.
+	IndexDigest string `json:"indexDigest,omitempty"`
+	IndexSize   int64  `json:"indexSize,omitempty"`
+}
+
+// Condition constants used with github.com/rancher/wrangler/pkg/condition.
+const (
+	RepoDownloaded         = "Downloaded"
+	FollowerRepoDownloaded = "FollowerDownloaded"
+)
+
+// Condition is a standard Kubernetes-style status condition.
+type Condition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	LastUpdateTime     string `json:"lastUpdateTime,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+}
@@ -0,0 +1,19 @@
+// Package content resolves a ClusterRepo's stored chart index for
+// consumers, such as the chart proxy and catalog API, that only have its
+// status. This is the one place that knows how to go from a RepoStatus
+// back to a *repo.IndexFile, so callers don't need to care whether the
+// index was persisted as chunked ConfigMaps or in the content-addressed
+// index store.
+package content
+
+import (
+	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/catalogv2/index"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// Index resolves status's stored chart index through store, whichever
+// index.Store implementation originally Saved it.
+func Index(store index.Store, namespace string, status *catalog.RepoStatus) (*repo.IndexFile, error) {
+	return store.Load(namespace, status)
+}
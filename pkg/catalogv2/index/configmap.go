@@ -0,0 +1,142 @@
+package index
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
+	namespaces "github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/wrangler/pkg/apply"
+	corev1controllers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	name2 "github.com/rancher/wrangler/pkg/name"
+	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ConfigMapStore is the original Store implementation: it splits the
+// gzipped index into maxChunkSize ConfigMaps owned by the ClusterRepo,
+// linked by catalog.cattle.io/next annotations. It is the default store,
+// since it requires no namespace or garbage collection of its own: the
+// ConfigMaps are cleaned up by Kubernetes when their owning ClusterRepo is
+// deleted.
+type ConfigMapStore struct {
+	apply          apply.Apply
+	configMapCache corev1controllers.ConfigMapCache
+}
+
+// NewConfigMapStore builds a ConfigMapStore that applies chunks through
+// apply and resolves them back again through configMapCache.
+func NewConfigMapStore(apply apply.Apply, configMapCache corev1controllers.ConfigMapCache) *ConfigMapStore {
+	return &ConfigMapStore{apply: apply, configMapCache: configMapCache}
+}
+
+func (s *ConfigMapStore) Save(namespace, name string, _ *repo.IndexFile, gzippedIndex []byte, owner metav1.OwnerReference, verifiedDigests map[string]string, status *catalog.RepoStatus) error {
+	// do this before we normalize the namespace
+	ownerObj := ownerObject(namespace, owner)
+
+	if namespace == "" {
+		namespace = namespaces.System
+	}
+
+	var (
+		objs  []runtime.Object
+		bytes = gzippedIndex
+		left  []byte
+		i     = 0
+		size  = len(gzippedIndex)
+	)
+
+	for {
+		if len(bytes) > maxChunkSize {
+			left = bytes[maxChunkSize:]
+			bytes = bytes[:maxChunkSize]
+		}
+
+		next := ""
+		if len(left) > 0 {
+			next = name2.SafeConcatName(owner.Name, fmt.Sprint(i+1), string(owner.UID))
+		}
+
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name2.SafeConcatName(owner.Name, fmt.Sprint(i), string(owner.UID)),
+				Namespace:       namespace,
+				OwnerReferences: []metav1.OwnerReference{owner},
+				Annotations: map[string]string{
+					"catalog.cattle.io/next": next,
+					// Size ensure the resource version should update even if this is the head of a multipart chunk
+					"catalog.cattle.io/size": fmt.Sprint(size),
+				},
+			},
+			BinaryData: map[string][]byte{
+				"content": bytes,
+			},
+		}
+
+		if i == 0 && len(verifiedDigests) > 0 {
+			digestsJSON, err := json.Marshal(verifiedDigests)
+			if err != nil {
+				return err
+			}
+			cm.Annotations[verify.VerifiedDigestsAnnotation] = string(digestsJSON)
+		}
+
+		objs = append(objs, cm)
+		if len(left) == 0 {
+			break
+		}
+
+		i++
+		bytes = left
+		left = nil
+	}
+
+	if err := s.apply.WithOwner(ownerObj).ApplyObjects(objs...); err != nil {
+		return err
+	}
+
+	head := objs[0].(*corev1.ConfigMap)
+	status.IndexConfigMapName = head.Name
+	status.IndexConfigMapNamespace = namespace
+	status.IndexConfigMapResourceVersion = head.ResourceVersion
+	status.IndexDigest = ""
+	status.IndexSize = 0
+	return nil
+}
+
+func (s *ConfigMapStore) Load(namespace string, status *catalog.RepoStatus) (*repo.IndexFile, error) {
+	if status.IndexConfigMapName == "" {
+		return nil, fmt.Errorf("index: status has no IndexConfigMapName to load")
+	}
+
+	ns := status.IndexConfigMapNamespace
+	if ns == "" {
+		ns = namespaces.System
+	}
+
+	gzippedIndex, err := readChunks(s.configMapCache, ns, status.IndexConfigMapName)
+	if err != nil {
+		return nil, err
+	}
+	return decodeIndex(gzippedIndex)
+}
+
+// readChunks concatenates the head ConfigMap name's BinaryData["content"]
+// with every chunk chained after it via the catalog.cattle.io/next
+// annotation.
+func readChunks(cache corev1controllers.ConfigMapCache, namespace, name string) ([]byte, error) {
+	var out bytes.Buffer
+	for name != "" {
+		cm, err := cache.Get(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(cm.BinaryData["content"])
+		name = cm.Annotations["catalog.cattle.io/next"]
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,366 @@
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
+	"github.com/rancher/wrangler/pkg/apply"
+	corev1controllers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// blobNamespace is the dedicated namespace content-addressed index blobs
+// are written to, independent of any ClusterRepo's own namespace. This is
+// what lets several repos pointed at the same upstream, or repeated
+// refreshes of an upstream whose contents did not change, share a single
+// blob instead of each owning a copy.
+const blobNamespace = "cattle-catalog-index"
+
+const (
+	// blobLabel marks the head chunk of every blob, so GC can list them
+	// without also matching their continuation chunks.
+	blobLabel = "catalog.cattle.io/index-blob"
+	// lastReferencedAnnotation is refreshed on every Save or Load that
+	// resolves a blob, so GC can find blobs nothing has used in a while
+	// without tracking a live reference count across every ClusterRepo.
+	lastReferencedAnnotation = "catalog.cattle.io/last-referenced"
+	// deltaParentAnnotation, when present on a blob's head chunk, means
+	// that chunk holds a delta (see diffEntries) against the blob named by
+	// this annotation rather than a full snapshot.
+	deltaParentAnnotation = "catalog.cattle.io/delta-parent"
+	// maxDeltaFraction caps how much of a repo's chart entries may differ
+	// from the parent blob before Save gives up on a delta and writes a
+	// full snapshot instead.
+	maxDeltaFraction = 0.25
+)
+
+// ContentAddressedStore is a Store that writes the gzipped index once under
+// its sha256 digest instead of once per ClusterRepo refresh, and records
+// only that digest and its size on RepoStatus. When only a handful of
+// charts changed since the digest already recorded on status, it persists
+// a small delta blob (parent digest -> changed entries) instead of the
+// full index, and Load reconstructs the full index by walking back to the
+// nearest full snapshot.
+type ContentAddressedStore struct {
+	apply          apply.Apply
+	configMaps     corev1controllers.ConfigMapClient
+	configMapCache corev1controllers.ConfigMapCache
+}
+
+// NewContentAddressedStore builds a ContentAddressedStore that applies
+// blobs through apply and resolves them back again through configMapCache.
+func NewContentAddressedStore(apply apply.Apply, configMaps corev1controllers.ConfigMapClient, configMapCache corev1controllers.ConfigMapCache) *ContentAddressedStore {
+	return &ContentAddressedStore{apply: apply, configMaps: configMaps, configMapCache: configMapCache}
+}
+
+func digestOf(gzippedIndex []byte) string {
+	sum := sha256.Sum256(gzippedIndex)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// blobName derives a ConfigMap name from digest. It uses the full sha256
+// hex digest (well under the 253-char k8s name limit) rather than a
+// truncated prefix, so two different blobs can never collide on name.
+func blobName(digest string) string {
+	return "idx-" + digest[len("sha256:"):]
+}
+
+func (s *ContentAddressedStore) Save(namespace, name string, idx *repo.IndexFile, gzippedIndex []byte, owner metav1.OwnerReference, verifiedDigests map[string]string, status *catalog.RepoStatus) error {
+	digest := digestOf(gzippedIndex)
+
+	if digest == status.IndexDigest {
+		// Unchanged since the last refresh; nothing to write, just confirm
+		// the chain backing it is still there and bump its last-referenced
+		// time, all the way up to its delta-parent chain (if any) so GC
+		// doesn't collect a parent this digest still depends on.
+		return s.touchChain(blobName(digest))
+	}
+
+	payload := gzippedIndex
+	parent := ""
+	if status.IndexDigest != "" {
+		if deltaPayload, ok, err := s.tryDelta(namespace, status, idx); err != nil {
+			return err
+		} else if ok {
+			payload = deltaPayload
+			parent = status.IndexDigest
+		}
+	}
+
+	if err := s.writeBlob(blobName(digest), payload, parent, verifiedDigests); err != nil {
+		return err
+	}
+
+	status.IndexDigest = digest
+	status.IndexSize = int64(len(gzippedIndex))
+	status.IndexConfigMapName = ""
+	status.IndexConfigMapNamespace = ""
+	status.IndexConfigMapResourceVersion = ""
+	return nil
+}
+
+// tryDelta attempts to express idx as a small delta against the blob
+// already recorded on status, returning ok=false if that blob can't be
+// loaded or the delta isn't small enough to be worth it.
+func (s *ContentAddressedStore) tryDelta(namespace string, status *catalog.RepoStatus, idx *repo.IndexFile) ([]byte, bool, error) {
+	parentIdx, err := s.Load(namespace, status)
+	if err != nil {
+		// The parent blob may have been GC'd out from under us; fall back
+		// to a full snapshot rather than failing the refresh.
+		return nil, false, nil
+	}
+
+	d := diffEntries(parentIdx, idx)
+	if !d.worthStoring(len(idx.Entries)) {
+		return nil, false, nil
+	}
+
+	deltaJSON, err := json.Marshal(d)
+	if err != nil {
+		return nil, false, err
+	}
+	return deltaJSON, true, nil
+}
+
+func (s *ContentAddressedStore) writeBlob(name string, payload []byte, deltaParent string, verifiedDigests map[string]string) error {
+	var (
+		objs []runtime.Object
+		left []byte
+		i    = 0
+		size = len(payload)
+	)
+
+	for {
+		chunk := payload
+		if len(chunk) > maxChunkSize {
+			left = chunk[maxChunkSize:]
+			chunk = chunk[:maxChunkSize]
+		}
+
+		next := ""
+		if len(left) > 0 {
+			next = fmt.Sprintf("%s-%d", name, i+1)
+		}
+
+		chunkName := name
+		if i > 0 {
+			chunkName = fmt.Sprintf("%s-%d", name, i)
+		}
+
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      chunkName,
+				Namespace: blobNamespace,
+				Annotations: map[string]string{
+					"catalog.cattle.io/next": next,
+					"catalog.cattle.io/size": fmt.Sprint(size),
+				},
+			},
+			BinaryData: map[string][]byte{
+				"content": chunk,
+			},
+		}
+
+		if i == 0 {
+			cm.Labels = map[string]string{blobLabel: "true"}
+			cm.Annotations[lastReferencedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+			if deltaParent != "" {
+				cm.Annotations[deltaParentAnnotation] = deltaParent
+			}
+			if len(verifiedDigests) > 0 {
+				digestsJSON, err := json.Marshal(verifiedDigests)
+				if err != nil {
+					return err
+				}
+				cm.Annotations[verify.VerifiedDigestsAnnotation] = string(digestsJSON)
+			}
+		}
+
+		objs = append(objs, cm)
+		if len(left) == 0 {
+			break
+		}
+		i++
+		payload = left
+		left = nil
+	}
+
+	// Blobs are shared across ClusterRepos (that's the point), so unlike
+	// ConfigMapStore's chunks they have no single owner to apply under.
+	return s.apply.ApplyObjects(objs...)
+}
+
+// touch bumps cm's last-referenced annotation to now, so GC won't consider
+// it (or, by extension, any blob whose chain was walked to reach it)
+// unreferenced.
+func (s *ContentAddressedStore) touch(cm *corev1.ConfigMap) error {
+	cm = cm.DeepCopy()
+	cm.Annotations[lastReferencedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	_, err := s.configMaps.Update(cm)
+	return err
+}
+
+// touchChain refreshes the last-referenced annotation on name and walks up
+// its delta-parent chain doing the same, without decoding any blob's
+// payload - used to confirm a digest already on status is still fully
+// backed, the same way load touches every ancestor it actually decodes.
+func (s *ContentAddressedStore) touchChain(name string) error {
+	for name != "" {
+		head, err := s.configMapCache.Get(blobNamespace, name)
+		if err != nil {
+			return err
+		}
+		if err := s.touch(head); err != nil {
+			return err
+		}
+		name = head.Annotations[deltaParentAnnotation]
+	}
+	return nil
+}
+
+func (s *ContentAddressedStore) Load(namespace string, status *catalog.RepoStatus) (*repo.IndexFile, error) {
+	if status.IndexDigest == "" {
+		return nil, fmt.Errorf("index: status has no IndexDigest to load")
+	}
+	return s.load(blobName(status.IndexDigest))
+}
+
+// load resolves name, touching it (and, recursively, every ancestor in its
+// delta chain) along the way. Every blob a live index still resolves
+// through gets its last-referenced annotation refreshed here, which is
+// what lets GC tell a delta's parent is still needed without maintaining a
+// separate reference count.
+func (s *ContentAddressedStore) load(name string) (*repo.IndexFile, error) {
+	head, err := s.configMapCache.Get(blobNamespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.touch(head); err != nil {
+		return nil, err
+	}
+
+	payload, err := readChunks(s.configMapCache, blobNamespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := head.Annotations[deltaParentAnnotation]
+	if parent == "" {
+		if got := blobName(digestOf(payload)); got != name {
+			return nil, fmt.Errorf("index: blob %s content does not match its digest (got %s)", name, got)
+		}
+		return decodeIndex(payload)
+	}
+
+	parentIdx, err := s.load(blobName(parent))
+	if err != nil {
+		return nil, fmt.Errorf("loading delta parent %s: %w", parent, err)
+	}
+
+	var d delta
+	if err := json.Unmarshal(payload, &d); err != nil {
+		return nil, fmt.Errorf("decoding delta: %w", err)
+	}
+	d.apply(parentIdx)
+	return parentIdx, nil
+}
+
+// GC deletes content-addressed blobs (and any chunks chained after them)
+// whose last-referenced annotation is older than olderThan. load refreshes
+// that annotation on every ancestor in a delta chain whenever a descendant
+// is resolved, so a blob that is still some other blob's delta parent
+// keeps being touched and stays out of GC's reach as long as that
+// descendant keeps getting loaded or re-Saved; it is only actually
+// collected once nothing has resolved its chain within the grace period.
+func (s *ContentAddressedStore) GC(olderThan time.Duration) error {
+	blobs, err := s.configMapCache.List(blobNamespace, labels.SelectorFromSet(labels.Set{blobLabel: "true"}))
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	for _, head := range blobs {
+		lastRef, err := time.Parse(time.RFC3339, head.Annotations[lastReferencedAnnotation])
+		if err != nil || lastRef.After(cutoff) {
+			continue
+		}
+
+		name := head.Name
+		for name != "" {
+			cm, err := s.configMapCache.Get(blobNamespace, name)
+			if err != nil {
+				break
+			}
+			next := cm.Annotations["catalog.cattle.io/next"]
+			if err := s.configMaps.Delete(blobNamespace, name, nil); err != nil {
+				return err
+			}
+			name = next
+		}
+	}
+	return nil
+}
+
+// delta is the JSON payload of a delta blob: the chart entries added or
+// changed since the parent blob, and the chart names removed from it.
+type delta struct {
+	Changed map[string][]*repo.ChartVersion `json:"changed,omitempty"`
+	Removed []string                        `json:"removed,omitempty"`
+}
+
+func (d delta) worthStoring(totalCharts int) bool {
+	changedCount := len(d.Changed) + len(d.Removed)
+	if totalCharts == 0 {
+		return false
+	}
+	return float64(changedCount)/float64(totalCharts) <= maxDeltaFraction
+}
+
+func (d delta) apply(base *repo.IndexFile) {
+	for _, name := range d.Removed {
+		delete(base.Entries, name)
+	}
+	for name, versions := range d.Changed {
+		base.Entries[name] = versions
+	}
+	base.SortEntries()
+}
+
+// diffEntries computes the delta needed to turn oldIdx into newIdx.
+func diffEntries(oldIdx, newIdx *repo.IndexFile) delta {
+	d := delta{Changed: map[string][]*repo.ChartVersion{}}
+
+	for name, versions := range newIdx.Entries {
+		oldVersions, ok := oldIdx.Entries[name]
+		if !ok || !sameVersions(oldVersions, versions) {
+			d.Changed[name] = versions
+		}
+	}
+	for name := range oldIdx.Entries {
+		if _, ok := newIdx.Entries[name]; !ok {
+			d.Removed = append(d.Removed, name)
+		}
+	}
+	return d
+}
+
+func sameVersions(a, b []*repo.ChartVersion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Version != b[i].Version || a[i].Digest != b[i].Digest {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+package index
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func chartVersion(name, version, digest string) *repo.ChartVersion {
+	return &repo.ChartVersion{
+		Metadata: &chart.Metadata{Name: name, Version: version},
+		Digest:   digest,
+	}
+}
+
+func TestGzipIndexRoundTrip(t *testing.T) {
+	idx := &repo.IndexFile{
+		Entries: map[string]repo.ChartVersions{
+			"nginx": {chartVersion("nginx", "1.0.0", "sha256:aaa")},
+		},
+	}
+	idx.SortEntries()
+
+	gzipped, err := GzipIndex(idx)
+	if err != nil {
+		t.Fatalf("GzipIndex: %v", err)
+	}
+
+	got, err := decodeIndex(gzipped)
+	if err != nil {
+		t.Fatalf("decodeIndex: %v", err)
+	}
+	if len(got.Entries) != 1 || got.Entries["nginx"][0].Digest != "sha256:aaa" {
+		t.Fatalf("round-tripped index mismatch: %+v", got.Entries)
+	}
+}
+
+func TestDigestOfIsStableAndContentAddressed(t *testing.T) {
+	a := []byte("hello")
+	b := []byte("hello")
+	c := []byte("world")
+
+	if digestOf(a) != digestOf(b) {
+		t.Fatal("digestOf should be deterministic for identical input")
+	}
+	if digestOf(a) == digestOf(c) {
+		t.Fatal("digestOf should differ for different input")
+	}
+	if got := digestOf(a); got[:7] != "sha256:" {
+		t.Fatalf("digestOf should be prefixed with sha256:, got %s", got)
+	}
+}
+
+func TestBlobNameUsesFullDigest(t *testing.T) {
+	digest := digestOf([]byte("hello"))
+	name := blobName(digest)
+	want := "idx-" + digest[len("sha256:"):]
+	if name != want {
+		t.Fatalf("blobName(%s) = %s, want %s", digest, name, want)
+	}
+	if len(name) < len("idx-")+64 {
+		t.Fatalf("blobName truncated the digest: %s", name)
+	}
+}
+
+func TestDiffEntriesChangedAndRemoved(t *testing.T) {
+	oldIdx := &repo.IndexFile{
+		Entries: map[string]repo.ChartVersions{
+			"nginx":  {chartVersion("nginx", "1.0.0", "sha256:aaa")},
+			"redis":  {chartVersion("redis", "1.0.0", "sha256:bbb")},
+			"stable": {chartVersion("stable", "1.0.0", "sha256:ccc")},
+		},
+	}
+	newIdx := &repo.IndexFile{
+		Entries: map[string]repo.ChartVersions{
+			"nginx":  {chartVersion("nginx", "1.0.0", "sha256:aaa")},
+			"redis":  {chartVersion("redis", "2.0.0", "sha256:ddd")},
+			"newapp": {chartVersion("newapp", "1.0.0", "sha256:eee")},
+		},
+	}
+
+	d := diffEntries(oldIdx, newIdx)
+
+	if _, ok := d.Changed["nginx"]; ok {
+		t.Error("nginx is unchanged and should not appear in Changed")
+	}
+	if _, ok := d.Changed["redis"]; !ok {
+		t.Error("redis changed version and should appear in Changed")
+	}
+	if _, ok := d.Changed["newapp"]; !ok {
+		t.Error("newapp is new and should appear in Changed")
+	}
+	if len(d.Removed) != 1 || d.Removed[0] != "stable" {
+		t.Errorf("expected stable to be Removed, got %v", d.Removed)
+	}
+}
+
+func TestDeltaApply(t *testing.T) {
+	base := &repo.IndexFile{
+		Entries: map[string]repo.ChartVersions{
+			"nginx": {chartVersion("nginx", "1.0.0", "sha256:aaa")},
+			"redis": {chartVersion("redis", "1.0.0", "sha256:bbb")},
+		},
+	}
+
+	d := delta{
+		Changed: map[string][]*repo.ChartVersion{
+			"redis": {chartVersion("redis", "2.0.0", "sha256:ddd")},
+		},
+		Removed: []string{"nginx"},
+	}
+
+	d.apply(base)
+
+	if _, ok := base.Entries["nginx"]; ok {
+		t.Error("nginx should have been removed by apply")
+	}
+	if base.Entries["redis"][0].Digest != "sha256:ddd" {
+		t.Errorf("redis should have been updated to sha256:ddd, got %s", base.Entries["redis"][0].Digest)
+	}
+}
+
+func TestDeltaWorthStoring(t *testing.T) {
+	small := delta{Changed: map[string][]*repo.ChartVersion{"a": nil}}
+	if !small.worthStoring(10) {
+		t.Error("1/10 changed should be worth storing as a delta")
+	}
+
+	large := delta{Changed: map[string][]*repo.ChartVersion{"a": nil, "b": nil, "c": nil}}
+	if large.worthStoring(10) {
+		t.Error("3/10 changed exceeds maxDeltaFraction and should not be worth storing")
+	}
+
+	empty := delta{}
+	if empty.worthStoring(0) {
+		t.Error("an index with zero charts should never be worth a delta")
+	}
+}
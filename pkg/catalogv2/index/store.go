@@ -0,0 +1,84 @@
+// Package index persists a ClusterRepo's downloaded Helm chart index and
+// resolves it again later. It exists so that how an index is stored is a
+// swappable concern from how it is downloaded and verified: ConfigMapStore
+// is the original design, chunking the gzipped index into 100KB ConfigMaps
+// owned by (and garbage collected with) each ClusterRepo. ContentAddressedStore
+// instead writes each distinct index once under its sha256 digest, so that
+// repeated refreshes of an unchanged upstream, or several repos pointed at
+// the same upstream, share a single blob instead of each paying the full
+// write and read cost on every refresh.
+package index
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+
+	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"helm.sh/helm/v3/pkg/repo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// maxChunkSize is the largest BinaryData payload either Store implementation
+// writes into a single ConfigMap before splitting the remainder into a
+// linked chunk.
+const maxChunkSize = 100_000
+
+// Store persists a downloaded index and can resolve it again from the
+// status fields (IndexConfigMap* or IndexDigest/IndexSize) a prior Save
+// recorded on a ClusterRepo's status.
+type Store interface {
+	// Save persists idx (already gzip-compressed and JSON-encoded as
+	// gzippedIndex) for the ClusterRepo identified by namespace/name/owner,
+	// and records where it was stored on status. verifiedDigests, when
+	// non-empty, is stored alongside the index so chart lookups can confirm
+	// a version was provenance-verified without re-checking its signature.
+	Save(namespace, name string, idx *repo.IndexFile, gzippedIndex []byte, owner metav1.OwnerReference, verifiedDigests map[string]string, status *catalog.RepoStatus) error
+	// Load resolves the index a prior Save recorded on status.
+	Load(namespace string, status *catalog.RepoStatus) (*repo.IndexFile, error)
+}
+
+// GzipIndex JSON-encodes and gzip-compresses idx, the form both Store
+// implementations persist.
+func GzipIndex(idx *repo.IndexFile) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	if err := json.NewEncoder(gz).Encode(idx); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeIndex reverses GzipIndex.
+func decodeIndex(gzippedIndex []byte) (*repo.IndexFile, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(gzippedIndex))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing index: %w", err)
+	}
+	defer gz.Close()
+
+	idx := &repo.IndexFile{}
+	if err := json.NewDecoder(gz).Decode(idx); err != nil {
+		return nil, fmt.Errorf("decoding index: %w", err)
+	}
+	return idx, nil
+}
+
+func ownerObject(namespace string, owner metav1.OwnerReference) runtime.Object {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       owner.Kind,
+			APIVersion: owner.APIVersion,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      owner.Name,
+			Namespace: namespace,
+			UID:       owner.UID,
+		},
+	}
+}
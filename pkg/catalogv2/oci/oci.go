@@ -0,0 +1,431 @@
+// Package oci implements downloading Helm chart indexes from OCI registries
+// (e.g. ECR, GHCR, Harbor) so that ClusterRepo can treat an `oci://` URL as a
+// first class download mode alongside GitRepo and plain HTTP index URLs.
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/yaml"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// Prefix identifies a RepoSpec.URL as pointing at an OCI registry rather
+	// than a Git remote or a plain Helm index.
+	Prefix = "oci://"
+
+	chartContentMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+)
+
+// IsOCI reports whether url should be handled by this package instead of the
+// Git or HTTP download paths.
+func IsOCI(url string) bool {
+	return strings.HasPrefix(url, Prefix)
+}
+
+// registryTransport performs authenticated requests against the OCI
+// Distribution v2 API for a single registry host.
+type registryTransport struct {
+	client   *http.Client
+	host     string
+	insecure bool
+	// authz is the static credential configured for this registry (Basic,
+	// from a username/password or dockerconfigjson secret). It is never
+	// sent to /v2/... endpoints directly; registries gate those behind a
+	// WWW-Authenticate: Bearer challenge (see do), and authz is only used
+	// to authenticate the resulting token exchange.
+	authz string
+
+	// bearer caches the "Bearer <token>" Authorization value obtained from
+	// the most recent token exchange, reused for later requests in the
+	// same DownloadIndex call until a 401 forces another exchange.
+	bearer string
+}
+
+// DownloadIndex authenticates against the registry referenced by repoURL,
+// enumerates its chart tags, pulls each chart artifact and synthesizes a
+// repo.IndexFile compatible with the chunked ConfigMap pipeline used for
+// Git and HTTP repos.
+func DownloadIndex(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSverify bool) (*repo.IndexFile, error) {
+	host, name, err := parseOCIURL(repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := newRegistryTransport(secret, host, caBundle, insecureSkipTLSverify)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := t.listTags(name)
+	if err != nil {
+		return nil, err
+	}
+
+	index := repo.NewIndexFile()
+	for _, tag := range tags {
+		metadata, err := t.pullChartMetadata(name, tag)
+		if err != nil {
+			return nil, fmt.Errorf("pulling %s:%s: %w", name, tag, err)
+		}
+		index.Entries[metadata.Name] = append(index.Entries[metadata.Name], &repo.ChartVersion{
+			Metadata: metadata,
+			URLs:     []string{fmt.Sprintf("%s%s/%s:%s", Prefix, host, name, tag)},
+		})
+	}
+
+	return index, nil
+}
+
+func parseOCIURL(repoURL string) (host, name string, err error) {
+	if !IsOCI(repoURL) {
+		return "", "", fmt.Errorf("invalid oci repository url %q, expected oci://host/name", repoURL)
+	}
+	trimmed := strings.TrimPrefix(repoURL, Prefix)
+	parts := strings.SplitN(trimmed, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid oci repository url %q, expected oci://host/name", repoURL)
+	}
+	return parts[0], strings.Trim(parts[1], "/"), nil
+}
+
+func newRegistryTransport(secret *corev1.Secret, host string, caBundle []byte, insecureSkipTLSverify bool) (*registryTransport, error) {
+	client, err := httpClientFor(caBundle, insecureSkipTLSverify)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &registryTransport{
+		client:   client,
+		host:     host,
+		insecure: insecureSkipTLSverify,
+	}
+
+	if secret != nil {
+		if user, pass := secret.Data[corev1.BasicAuthUsernameKey], secret.Data[corev1.BasicAuthPasswordKey]; len(user) > 0 {
+			t.authz = "Basic " + base64.StdEncoding.EncodeToString([]byte(string(user)+":"+string(pass)))
+		} else if dockerconfig, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
+			authz, err := authFromDockerConfig(dockerconfig, host)
+			if err != nil {
+				return nil, err
+			}
+			t.authz = authz
+		}
+	}
+
+	return t, nil
+}
+
+func authFromDockerConfig(dockerconfig []byte, host string) (string, error) {
+	var cfg struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(dockerconfig, &cfg); err != nil {
+		return "", fmt.Errorf("parsing dockerconfigjson pull secret: %w", err)
+	}
+	entry, ok := cfg.Auths[host]
+	if !ok {
+		return "", nil
+	}
+	return "Basic " + entry.Auth, nil
+}
+
+func (t *registryTransport) listTags(name string) ([]string, error) {
+	req, err := t.newRequest("GET", fmt.Sprintf("/v2/%s/tags/list", name))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing tags for %s: unexpected status %s", name, resp.Status)
+	}
+
+	var body struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body.Tags, nil
+}
+
+// pullChartMetadata fetches the chart artifact for name:tag and returns the
+// Chart.yaml embedded in it.
+func (t *registryTransport) pullChartMetadata(name, tag string) (*chart.Metadata, error) {
+	manifest, err := t.getManifest(name, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	layerDigest, ok := firstLayerDigest(manifest, chartContentMediaType)
+	if !ok {
+		return nil, fmt.Errorf("no %s layer found for %s:%s", chartContentMediaType, name, tag)
+	}
+
+	req, err := t.newRequest("GET", fmt.Sprintf("/v2/%s/blobs/%s", name, layerDigest))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching chart blob %s: unexpected status %s", layerDigest, resp.Status)
+	}
+
+	return chartYAMLFromTarGz(resp.Body)
+}
+
+func (t *registryTransport) getManifest(name, tag string) (map[string]interface{}, error) {
+	req, err := t.newRequest("GET", fmt.Sprintf("/v2/%s/manifests/%s", name, tag))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.oci.image.manifest.v1+json")
+
+	resp, err := t.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching manifest for %s:%s: unexpected status %s", name, tag, resp.Status)
+	}
+
+	var manifest map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func firstLayerDigest(manifest map[string]interface{}, mediaType string) (string, bool) {
+	layers, _ := manifest["layers"].([]interface{})
+	for _, l := range layers {
+		layer, ok := l.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if layer["mediaType"] == mediaType {
+			digest, _ := layer["digest"].(string)
+			return digest, digest != ""
+		}
+	}
+	return "", false
+}
+
+func chartYAMLFromTarGz(r io.Reader) (*chart.Metadata, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(hdr.Name, "Chart.yaml") && strings.Count(hdr.Name, "/") <= 1 {
+			metadata := new(chart.Metadata)
+			if err := decodeYAML(tr, metadata); err != nil {
+				return nil, err
+			}
+			return metadata, nil
+		}
+	}
+	return nil, fmt.Errorf("Chart.yaml not found in chart artifact")
+}
+
+func (t *registryTransport) newRequest(method, path string) (*http.Request, error) {
+	scheme := "https"
+	return http.NewRequest(method, scheme+"://"+t.host+path, nil)
+}
+
+// do sends req against the registry, transparently handling the OCI
+// Distribution v2 bearer challenge flow: on a 401 with a
+// WWW-Authenticate: Bearer challenge, it exchanges t.authz for a token at
+// the challenge's realm and retries req once with that token. Plain Basic
+// or dockerconfigjson credentials are never sent straight to /v2/...
+// endpoints, since registries such as ECR, GHCR and Harbor only accept
+// them at the token endpoint.
+func (t *registryTransport) do(req *http.Request) (*http.Response, error) {
+	if t.bearer != "" {
+		req.Header.Set("Authorization", t.bearer)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	token, err := t.exchangeToken(challenge)
+	if err != nil {
+		return nil, fmt.Errorf("authenticating to %s: %w", t.host, err)
+	}
+	t.bearer = "Bearer " + token
+
+	retry := req.Clone(req.Context())
+	retry.Header.Set("Authorization", t.bearer)
+	return t.client.Do(retry)
+}
+
+// exchangeToken performs the token request described by a
+// WWW-Authenticate: Bearer challenge (realm, service, scope) and returns
+// the bearer token to retry the original request with. t.authz, if set, is
+// sent to the token endpoint as Basic auth; GHCR's anonymous public pulls
+// and similar work the same way with no credentials at all.
+func (t *registryTransport) exchangeToken(challenge string) (string, error) {
+	realm, params, err := parseBearerChallenge(challenge)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("GET", realm, nil)
+	if err != nil {
+		return "", err
+	}
+	q := req.URL.Query()
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	req.URL.RawQuery = q.Encode()
+	if t.authz != "" {
+		req.Header.Set("Authorization", t.authz)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint %s: unexpected status %s", realm, resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("token endpoint %s returned no token", realm)
+}
+
+// parseBearerChallenge parses a WWW-Authenticate header of the form
+// `Bearer realm="...",service="...",scope="..."` into its realm and the
+// remaining key/value params to pass to the token endpoint.
+func parseBearerChallenge(challenge string) (realm string, params map[string]string, err error) {
+	const prefix = "Bearer "
+	if !strings.HasPrefix(challenge, prefix) {
+		return "", nil, fmt.Errorf("unsupported WWW-Authenticate challenge %q", challenge)
+	}
+
+	params = map[string]string{}
+	for _, part := range splitChallengeParams(strings.TrimPrefix(challenge, prefix)) {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(kv[0])
+		val := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		if key == "realm" {
+			realm = val
+			continue
+		}
+		params[key] = val
+	}
+	if realm == "" {
+		return "", nil, fmt.Errorf("WWW-Authenticate challenge %q has no realm", challenge)
+	}
+	return realm, params, nil
+}
+
+// splitChallengeParams splits a Bearer challenge's comma-separated
+// key="value" pairs, without breaking on commas inside quoted values.
+func splitChallengeParams(s string) []string {
+	var parts []string
+	var inQuotes bool
+	start := 0
+	for i, r := range s {
+		switch r {
+		case '"':
+			inQuotes = !inQuotes
+		case ',':
+			if !inQuotes {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func decodeYAML(r io.Reader, out interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+func httpClientFor(caBundle []byte, insecureSkipTLSverify bool) (*http.Client, error) {
+	if len(caBundle) == 0 && !insecureSkipTLSverify {
+		return http.DefaultClient, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if len(caBundle) > 0 {
+		pool.AppendCertsFromPEM(caBundle)
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:            pool,
+				InsecureSkipVerify: insecureSkipTLSverify,
+			},
+		},
+	}, nil
+}
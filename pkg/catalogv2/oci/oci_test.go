@@ -0,0 +1,104 @@
+package oci
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestParseOCIURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		wantHost string
+		wantName string
+		wantErr  bool
+	}{
+		{url: "oci://registry.example.com/charts/nginx", wantHost: "registry.example.com", wantName: "charts/nginx"},
+		{url: "oci://registry.example.com/charts/nginx/", wantHost: "registry.example.com", wantName: "charts/nginx"},
+		{url: "oci://registry.example.com", wantErr: true},
+		{url: "oci://registry.example.com/", wantErr: true},
+		{url: "https://registry.example.com/charts/nginx", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		host, name, err := parseOCIURL(tt.url)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseOCIURL(%q): expected error, got host=%q name=%q", tt.url, host, name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseOCIURL(%q): unexpected error: %v", tt.url, err)
+			continue
+		}
+		if host != tt.wantHost || name != tt.wantName {
+			t.Errorf("parseOCIURL(%q) = (%q, %q), want (%q, %q)", tt.url, host, name, tt.wantHost, tt.wantName)
+		}
+	}
+}
+
+func TestAuthFromDockerConfig(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	dockerconfig := []byte(`{"auths":{"registry.example.com":{"auth":"` + auth + `"}}}`)
+
+	got, err := authFromDockerConfig(dockerconfig, "registry.example.com")
+	if err != nil {
+		t.Fatalf("authFromDockerConfig: %v", err)
+	}
+	if want := "Basic " + auth; got != want {
+		t.Errorf("authFromDockerConfig = %q, want %q", got, want)
+	}
+
+	got, err = authFromDockerConfig(dockerconfig, "other.example.com")
+	if err != nil {
+		t.Fatalf("authFromDockerConfig for missing host: %v", err)
+	}
+	if got != "" {
+		t.Errorf("authFromDockerConfig for a host with no entry should return empty, got %q", got)
+	}
+
+	if _, err := authFromDockerConfig([]byte("not json"), "registry.example.com"); err == nil {
+		t.Error("authFromDockerConfig should error on invalid JSON")
+	}
+}
+
+func TestParseBearerChallenge(t *testing.T) {
+	challenge := `Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:charts/nginx:pull"`
+
+	realm, params, err := parseBearerChallenge(challenge)
+	if err != nil {
+		t.Fatalf("parseBearerChallenge: %v", err)
+	}
+	if realm != "https://auth.example.com/token" {
+		t.Errorf("realm = %q, want https://auth.example.com/token", realm)
+	}
+	if params["service"] != "registry.example.com" {
+		t.Errorf("service = %q, want registry.example.com", params["service"])
+	}
+	if params["scope"] != "repository:charts/nginx:pull" {
+		t.Errorf("scope = %q, want repository:charts/nginx:pull", params["scope"])
+	}
+	if _, ok := params["realm"]; ok {
+		t.Error("realm should not also appear in params")
+	}
+
+	if _, _, err := parseBearerChallenge("Basic realm=\"x\""); err == nil {
+		t.Error("parseBearerChallenge should reject non-Bearer challenges")
+	}
+	if _, _, err := parseBearerChallenge(`Bearer service="registry.example.com"`); err == nil {
+		t.Error("parseBearerChallenge should require a realm")
+	}
+}
+
+func TestSplitChallengeParams(t *testing.T) {
+	got := splitChallengeParams(`realm="https://a,b.example.com/token",service="x",scope="y,z"`)
+	want := []string{`realm="https://a,b.example.com/token"`, `service="x"`, `scope="y,z"`}
+	if len(got) != len(want) {
+		t.Fatalf("splitChallengeParams returned %d parts, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("part %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
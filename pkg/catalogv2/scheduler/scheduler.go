@@ -0,0 +1,177 @@
+// Package scheduler tracks a per-ClusterRepo refresh cadence with
+// exponential backoff on failure, replacing a single package-level refresh
+// interval that requeued every ClusterRepo on the same boundary. That made
+// hundreds of repos wake up together after a Rancher restart and made a slow
+// or unreachable git remote retry every 5 minutes instead of backing off.
+package scheduler
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	backoffBase = 30 * time.Second
+	backoffCap  = 30 * time.Minute
+	jitterFrac  = 0.2
+)
+
+// Outcome is whether a ClusterRepo's last refresh attempt succeeded.
+type Outcome int
+
+const (
+	// Success resets a repo's backoff and schedules it at its configured
+	// cadence.
+	Success Outcome = iota
+	// Failure advances a repo's exponential backoff.
+	Failure
+)
+
+// EnqueueFunc matches the wrangler controller method used to requeue a
+// cluster-scoped resource after a delay, e.g.
+// catalogcontrollers.ClusterRepoController.EnqueueAfter.
+type EnqueueFunc func(name string, delay time.Duration)
+
+// Spec is the per-repo cadence configuration: an explicit fixed interval, a
+// cron expression, or (if neither is set) Default.
+type Spec struct {
+	Interval time.Duration
+	Cron     string
+	Default  time.Duration
+}
+
+// Cadence returns the delay until the next scheduled run on success.
+func (s Spec) Cadence() time.Duration {
+	if s.Cron != "" {
+		if schedule, err := cron.ParseStandard(s.Cron); err == nil {
+			return time.Until(schedule.Next(time.Now()))
+		}
+	}
+	if s.Interval > 0 {
+		return s.Interval
+	}
+	return s.Default
+}
+
+// Due reports whether a repo last downloaded at lastDownload is due for
+// another refresh now. This is deliberately not "lastDownload + Cadence()
+// has passed": for a cron Spec.Cron, Cadence() is forward-looking (time
+// until the next fire from now), and shrinks as now approaches that fire,
+// so reusing it here would make a repo due around the midpoint between
+// its last download and its next cron mark instead of at the mark itself.
+// Due instead checks whether the next scheduled fire after lastDownload has
+// already passed.
+func (s Spec) Due(lastDownload time.Time) bool {
+	if lastDownload.IsZero() {
+		return true
+	}
+	if s.Cron != "" {
+		if schedule, err := cron.ParseStandard(s.Cron); err == nil {
+			return !schedule.Next(lastDownload).After(time.Now())
+		}
+	}
+	interval := s.Interval
+	if interval <= 0 {
+		interval = s.Default
+	}
+	return time.Since(lastDownload) >= interval
+}
+
+var (
+	nextRunGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "rancher",
+		Subsystem: "catalog",
+		Name:      "clusterrepo_next_run_timestamp_seconds",
+		Help:      "Unix timestamp of the next scheduled refresh for a ClusterRepo.",
+	}, []string{"name"})
+
+	consecutiveFailuresGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "rancher",
+		Subsystem: "catalog",
+		Name:      "clusterrepo_consecutive_failures",
+		Help:      "Consecutive failed refresh attempts for a ClusterRepo.",
+	}, []string{"name"})
+)
+
+func init() {
+	prometheus.MustRegister(nextRunGauge, consecutiveFailuresGauge)
+}
+
+type repoState struct {
+	consecutiveFailures int
+}
+
+// Scheduler schedules each ClusterRepo's next refresh independently,
+// applying exponential backoff (base 30s, cap 30m, ±20% jitter) after
+// consecutive failures and resetting to the configured cadence on success.
+type Scheduler struct {
+	enqueue EnqueueFunc
+
+	mu     sync.Mutex
+	states map[string]*repoState
+}
+
+// New builds a Scheduler that requeues repos through enqueue, typically
+// ClusterRepoController.EnqueueAfter.
+func New(enqueue EnqueueFunc) *Scheduler {
+	return &Scheduler{
+		enqueue: enqueue,
+		states:  map[string]*repoState{},
+	}
+}
+
+// EnqueueNext records outcome for name and requeues it: at Spec's cadence on
+// Success, or after an exponential backoff on Failure.
+func (s *Scheduler) EnqueueNext(name string, spec Spec, outcome Outcome) {
+	s.mu.Lock()
+	state, ok := s.states[name]
+	if !ok {
+		state = &repoState{}
+		s.states[name] = state
+	}
+
+	var delay time.Duration
+	switch outcome {
+	case Success:
+		state.consecutiveFailures = 0
+		delay = spec.Cadence()
+	case Failure:
+		state.consecutiveFailures++
+		delay = backoff(state.consecutiveFailures)
+	}
+	failures := state.consecutiveFailures
+	s.mu.Unlock()
+
+	nextRunGauge.WithLabelValues(name).Set(float64(time.Now().Add(delay).Unix()))
+	consecutiveFailuresGauge.WithLabelValues(name).Set(float64(failures))
+
+	s.enqueue(name, delay)
+}
+
+// Forget drops name's tracked state and gauges, e.g. once its ClusterRepo is
+// deleted.
+func (s *Scheduler) Forget(name string) {
+	s.mu.Lock()
+	delete(s.states, name)
+	s.mu.Unlock()
+
+	nextRunGauge.DeleteLabelValues(name)
+	consecutiveFailuresGauge.DeleteLabelValues(name)
+}
+
+// backoff returns base*2^(n-1), capped and jittered by ±jitterFrac.
+func backoff(consecutiveFailures int) time.Duration {
+	delay := backoffCap
+	if shift := consecutiveFailures - 1; shift >= 0 && shift < 32 {
+		if scaled := backoffBase * time.Duration(uint64(1)<<uint(shift)); scaled > 0 && scaled < backoffCap {
+			delay = scaled
+		}
+	}
+
+	jitter := time.Duration(float64(delay) * jitterFrac * (rand.Float64()*2 - 1))
+	return delay + jitter
+}
@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffBounds(t *testing.T) {
+	for failures := 1; failures <= 10; failures++ {
+		base := backoffBase
+		if shift := failures - 1; shift < 32 {
+			if scaled := backoffBase * time.Duration(uint64(1)<<uint(shift)); scaled > 0 && scaled < backoffCap {
+				base = scaled
+			} else {
+				base = backoffCap
+			}
+		}
+		lo := time.Duration(float64(base) * (1 - jitterFrac))
+		hi := time.Duration(float64(base) * (1 + jitterFrac))
+
+		for i := 0; i < 20; i++ {
+			got := backoff(failures)
+			if got < lo || got > hi {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", failures, got, lo, hi)
+			}
+		}
+	}
+}
+
+func TestBackoffCapsAtLargeFailureCounts(t *testing.T) {
+	got := backoff(1000)
+	lo := time.Duration(float64(backoffCap) * (1 - jitterFrac))
+	hi := time.Duration(float64(backoffCap) * (1 + jitterFrac))
+	if got < lo || got > hi {
+		t.Fatalf("backoff(1000) = %v, want within [%v, %v] (capped)", got, lo, hi)
+	}
+}
+
+func TestSpecDueZeroLastDownload(t *testing.T) {
+	s := Spec{Default: time.Hour}
+	if !s.Due(time.Time{}) {
+		t.Error("a repo never downloaded should always be due")
+	}
+}
+
+func TestSpecDueInterval(t *testing.T) {
+	s := Spec{Interval: time.Hour}
+	if s.Due(time.Now().Add(-30 * time.Minute)) {
+		t.Error("downloaded 30m ago with a 1h interval should not be due yet")
+	}
+	if !s.Due(time.Now().Add(-2 * time.Hour)) {
+		t.Error("downloaded 2h ago with a 1h interval should be due")
+	}
+}
+
+func TestSpecDueCronUsesFixedNextFireNotCadence(t *testing.T) {
+	// Every 6 hours, on the hour.
+	s := Spec{Cron: "0 */6 * * *"}
+
+	// Downloaded a minute ago: the next cron mark after that download has
+	// not been reached yet, so not due, even though Cadence() keeps
+	// shrinking as now approaches that mark (the bug this method replaces).
+	lastDownload := time.Now().Add(-1 * time.Minute)
+	if s.Due(lastDownload) {
+		t.Error("a repo downloaded just now should not be due again before the next cron mark")
+	}
+}
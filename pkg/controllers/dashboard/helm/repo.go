@@ -1,38 +1,40 @@
 package helm
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
-	"encoding/json"
 	"fmt"
+	"net/http"
+	"path/filepath"
 	"time"
 
 	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/catalogv2"
+	"github.com/rancher/rancher/pkg/catalogv2/content"
 	"github.com/rancher/rancher/pkg/catalogv2/git"
 	helmhttp "github.com/rancher/rancher/pkg/catalogv2/http"
+	"github.com/rancher/rancher/pkg/catalogv2/index"
+	"github.com/rancher/rancher/pkg/catalogv2/oci"
+	"github.com/rancher/rancher/pkg/catalogv2/scheduler"
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
 	catalogcontrollers "github.com/rancher/rancher/pkg/generated/controllers/catalog.cattle.io/v1"
-	namespaces "github.com/rancher/rancher/pkg/namespace"
 	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/wrangler/pkg/apply"
 	"github.com/rancher/wrangler/pkg/condition"
 	corev1controllers "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
-	name2 "github.com/rancher/wrangler/pkg/name"
+	"github.com/sirupsen/logrus"
 	"helm.sh/helm/v3/pkg/repo"
-	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 )
 
-const (
-	maxSize = 100_000
-)
+// gitRepoBaseDir is the local checkout root used by the git package to
+// clone GitRepo based ClusterRepos, so that provenance verification can
+// find a chart's sibling .tgz.prov file in the working tree.
+const gitRepoBaseDir = "management-state/git-repo"
 
-var (
-	interval = 5 * time.Minute
-)
+// defaultInterval is the refresh cadence used for a ClusterRepo that sets
+// neither Spec.RefreshInterval nor Spec.RefreshSchedule.
+const defaultInterval = 5 * time.Minute
 
 type repoHandler struct {
 	// secrets is a cache for Kubernetes secrets used to store Helm chart repository credentials and other sensitive data.
@@ -44,6 +46,40 @@ type repoHandler struct {
 	// configMapCache is a cache for Kubernetes ConfigMap resources, providing a way to quickly lookup ConfigMap resources in memory.
 	configMapCache corev1controllers.ConfigMapCache
 	apply          apply.Apply
+	// scheduler tracks each ClusterRepo's own refresh cadence and backoff,
+	// rather than requeuing every repo after the same package-level interval.
+	scheduler *scheduler.Scheduler
+
+	// configMapStore is the default index.Store: a gzipped index chunked
+	// into ConfigMaps owned by the ClusterRepo.
+	configMapStore *index.ConfigMapStore
+	// contentStore is used instead of configMapStore once a downloaded
+	// index's gzipped size passes contentAddressedThreshold, so that large
+	// catalogs are written once under their digest rather than chunked into
+	// ConfigMaps on every refresh.
+	contentStore *index.ContentAddressedStore
+}
+
+// contentAddressedThreshold is the gzipped index size above which download
+// switches from configMapStore to contentStore.
+const contentAddressedThreshold = 500_000
+
+// indexStoreFor picks the index.Store to Save a gzipped index of size
+// bytes with.
+func (r *repoHandler) indexStoreFor(size int) index.Store {
+	if size > contentAddressedThreshold {
+		return r.contentStore
+	}
+	return r.configMapStore
+}
+
+// loadIndexStoreFor picks the index.Store that Saved status's currently
+// recorded index.
+func (r *repoHandler) loadIndexStoreFor(status *catalog.RepoStatus) index.Store {
+	if status.IndexDigest != "" {
+		return r.contentStore
+	}
+	return r.configMapStore
 }
 
 // Register Callbacks
@@ -57,17 +93,56 @@ func RegisterRepos(ctx context.Context,
 	clusterRepos catalogcontrollers.ClusterRepoController,
 	configMap corev1controllers.ConfigMapController,
 	configMapCache corev1controllers.ConfigMapCache) {
+	repoApply := apply.WithCacheTypes(configMap).WithStrictCaching().WithSetOwnerReference(false, false)
 	h := &repoHandler{
 		secrets:        secrets,
 		clusterRepos:   clusterRepos,
 		configMaps:     configMap,
 		configMapCache: configMapCache,
-		apply:          apply.WithCacheTypes(configMap).WithStrictCaching().WithSetOwnerReference(false, false),
+		apply:          repoApply,
+		scheduler:      scheduler.New(clusterRepos.EnqueueAfter),
+		configMapStore: index.NewConfigMapStore(repoApply, configMapCache),
+		contentStore:   index.NewContentAddressedStore(repoApply, configMap, configMapCache),
 	}
 
 	catalogcontrollers.RegisterClusterRepoStatusHandler(ctx, clusterRepos,
 		condition.Cond(catalog.RepoDownloaded), "helm-clusterrepo-download", h.ClusterRepoDownloadStatusHandler)
+	clusterRepos.OnRemove(ctx, "helm-clusterrepo-scheduler-forget", h.onRemove)
 
+	go h.runContentStoreGC(ctx)
+}
+
+// onRemove drops a deleted ClusterRepo's tracked scheduler state and
+// Prometheus series, so they don't leak indefinitely for every repo ever
+// created and removed.
+func (h *repoHandler) onRemove(_ string, repo *catalog.ClusterRepo) (*catalog.ClusterRepo, error) {
+	h.scheduler.Forget(repo.Name)
+	return repo, nil
+}
+
+// indexBlobGCInterval is how often runContentStoreGC sweeps the
+// content-addressed index store for blobs nothing has referenced in
+// indexBlobGCGrace.
+const (
+	indexBlobGCInterval = time.Hour
+	indexBlobGCGrace    = 24 * time.Hour
+)
+
+// runContentStoreGC periodically deletes content-addressed index blobs that
+// no ClusterRepo has referenced in indexBlobGCGrace, until ctx is done.
+func (r *repoHandler) runContentStoreGC(ctx context.Context) {
+	ticker := time.NewTicker(indexBlobGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.contentStore.GC(indexBlobGCGrace); err != nil {
+				logrus.Errorf("failed to garbage collect content-addressed chart indexes: %v", err)
+			}
+		}
+	}
 }
 
 // RegisterReposForFollowers function is responsible for registering the handler for repositories for follower nodes in Rancher.
@@ -79,11 +154,12 @@ func RegisterReposForFollowers(ctx context.Context,
 	h := &repoHandler{
 		secrets:      secrets,
 		clusterRepos: clusterRepos,
+		scheduler:    scheduler.New(clusterRepos.EnqueueAfter),
 	}
 
 	catalogcontrollers.RegisterClusterRepoStatusHandler(ctx, clusterRepos,
 		condition.Cond(catalog.FollowerRepoDownloaded), "helm-clusterrepo-ensure", h.ClusterRepoDownloadEnsureStatusHandler)
-
+	clusterRepos.OnRemove(ctx, "helm-clusterrepo-scheduler-forget", h.onRemove)
 }
 
 // Callbacks with system logic
@@ -91,8 +167,13 @@ func RegisterReposForFollowers(ctx context.Context,
 // ClusterRepoDownloadEnsureStatusHandler method ensures that the repository is always up-to-date
 // with clusterRepo.Status and Spec
 func (r *repoHandler) ClusterRepoDownloadEnsureStatusHandler(repo *catalog.ClusterRepo, status catalog.RepoStatus) (catalog.RepoStatus, error) {
-	r.clusterRepos.EnqueueAfter(repo.Name, interval)
-	return r.ensure(&repo.Spec, status, &repo.ObjectMeta)
+	newStatus, err := r.ensure(&repo.Spec, status, &repo.ObjectMeta)
+	outcome := scheduler.Success
+	if err != nil {
+		outcome = scheduler.Failure
+	}
+	r.scheduler.EnqueueNext(repo.Name, scheduleSpecFor(&repo.Spec), outcome)
+	return newStatus, err
 }
 
 // ClusterRepoDownloadStatusHandler is responsible for creating/update of the GitHub folder
@@ -103,30 +184,35 @@ func (r *repoHandler) ClusterRepoDownloadStatusHandler(repo *catalog.ClusterRepo
 		return status, err
 	}
 	if !shouldRefresh(&repo.Spec, &status) {
-		r.clusterRepos.EnqueueAfter(repo.Name, interval)
+		r.scheduler.EnqueueNext(repo.Name, scheduleSpecFor(&repo.Spec), scheduler.Success)
 		return status, nil
 	}
 
-	return r.download(&repo.Spec, status, &repo.ObjectMeta, metav1.OwnerReference{
+	newStatus, err := r.download(&repo.Spec, status, &repo.ObjectMeta, metav1.OwnerReference{
 		APIVersion: catalog.SchemeGroupVersion.Group + "/" + catalog.SchemeGroupVersion.Version,
 		Kind:       "ClusterRepo",
 		Name:       repo.Name,
 		UID:        repo.UID,
 	})
+
+	outcome := scheduler.Success
+	if err != nil {
+		outcome = scheduler.Failure
+	}
+	r.scheduler.EnqueueNext(repo.Name, scheduleSpecFor(&repo.Spec), outcome)
+
+	return newStatus, err
 }
 
-func toOwnerObject(namespace string, owner metav1.OwnerReference) runtime.Object {
-	return &metav1.PartialObjectMetadata{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       owner.Kind,
-			APIVersion: owner.APIVersion,
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      owner.Name,
-			Namespace: namespace,
-			UID:       owner.UID,
-		},
+// scheduleSpecFor translates a RepoSpec's refresh configuration into the
+// scheduler.Spec used to compute its next run.
+func scheduleSpecFor(spec *catalog.RepoSpec) scheduler.Spec {
+	s := scheduler.Spec{Default: defaultInterval}
+	if spec.RefreshInterval != nil {
+		s.Interval = spec.RefreshInterval.Duration
 	}
+	s.Cron = spec.RefreshSchedule
+	return s
 }
 
 // The ensure method makes sure that a repo exists and is ready based on the provided RepoSpec
@@ -153,74 +239,9 @@ func (r *repoHandler) ensure(repoSpec *catalog.RepoSpec, status catalog.RepoStat
 	return status, repo.Ensure(status.Branch)
 }
 
-func (r *repoHandler) createOrUpdateMap(namespace, name string, index *repo.IndexFile, owner metav1.OwnerReference) (*corev1.ConfigMap, error) {
-	// do this before we normalize the namespace
-	ownerObject := toOwnerObject(namespace, owner)
-
-	buf := &bytes.Buffer{}
-	gz := gzip.NewWriter(buf)
-	if err := json.NewEncoder(gz).Encode(index); err != nil {
-		return nil, err
-	}
-	if err := gz.Close(); err != nil {
-		return nil, err
-	}
-
-	if namespace == "" {
-		namespace = namespaces.System
-	}
-
-	var (
-		objs  []runtime.Object
-		bytes = buf.Bytes()
-		left  []byte
-		i     = 0
-		size  = len(bytes)
-	)
-
-	for {
-		if len(bytes) > maxSize {
-			left = bytes[maxSize:]
-			bytes = bytes[:maxSize]
-		}
-
-		next := ""
-		if len(left) > 0 {
-			next = name2.SafeConcatName(owner.Name, fmt.Sprint(i+1), string(owner.UID))
-		}
-
-		cm := &corev1.ConfigMap{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:            name2.SafeConcatName(owner.Name, fmt.Sprint(i), string(owner.UID)),
-				Namespace:       namespace,
-				OwnerReferences: []metav1.OwnerReference{owner},
-				Annotations: map[string]string{
-					"catalog.cattle.io/next": next,
-					// Size ensure the resource version should update even if this is the head of a multipart chunk
-					"catalog.cattle.io/size": fmt.Sprint(size),
-				},
-			},
-			BinaryData: map[string][]byte{
-				"content": bytes,
-			},
-		}
-
-		objs = append(objs, cm)
-		if len(left) == 0 {
-			break
-		}
-
-		i++
-		bytes = left
-		left = nil
-	}
-
-	return objs[0].(*corev1.ConfigMap), r.apply.WithOwner(ownerObject).ApplyObjects(objs...)
-}
-
 func (r *repoHandler) download(repoSpec *catalog.RepoSpec, status catalog.RepoStatus, metadata *metav1.ObjectMeta, owner metav1.OwnerReference) (catalog.RepoStatus, error) {
 	var (
-		index  *repo.IndexFile
+		idx    *repo.IndexFile
 		commit string
 		err    error
 	)
@@ -262,45 +283,149 @@ func (r *repoHandler) download(repoSpec *catalog.RepoSpec, status catalog.RepoSt
 			}
 		}
 		// regardless of which download operation took place, build or get the new index
-		index, err = git.BuildOrGetIndex(metadata.Namespace, metadata.Name, repoSpec.GitRepo)
-		if err != nil || index == nil {
+		idx, err = git.BuildOrGetIndex(metadata.Namespace, metadata.Name, repoSpec.GitRepo)
+		if err != nil || idx == nil {
 			return status, err
 		}
+	} else if oci.IsOCI(repoSpec.URL) {
+		status.URL = repoSpec.URL
+		status.Branch = ""
+		idx, err = oci.DownloadIndex(secret, repoSpec.URL, repoSpec.CABundle, repoSpec.InsecureSkipTLSverify)
 	} else if repoSpec.URL != "" {
 		status.URL = repoSpec.URL
 		status.Branch = ""
-		index, err = helmhttp.DownloadIndex(secret, repoSpec.URL, repoSpec.CABundle, repoSpec.InsecureSkipTLSverify, repoSpec.DisableSameOriginCheck)
+		idx, err = helmhttp.DownloadIndex(secret, repoSpec.URL, repoSpec.CABundle, repoSpec.InsecureSkipTLSverify, repoSpec.DisableSameOriginCheck)
 	} else {
 		return status, nil
 	}
-	if err != nil || index == nil {
+	if err != nil || idx == nil {
 		return status, err
 	}
 
-	index.SortEntries()
+	idx.SortEntries()
+
+	var verifiedDigests map[string]string
+	if verify.ModeFor(repoSpec.Verification) != catalog.VerifyModeOff {
+		if oci.IsOCI(repoSpec.URL) {
+			return status, fmt.Errorf("chart provenance verification is not supported for oci:// repositories")
+		}
+		gitDir := ""
+		if repoSpec.GitRepo != "" {
+			gitDir = filepath.Join(gitRepoBaseDir, metadata.Namespace, metadata.Name)
+		}
+		verifiedDigests, err = r.verifyIndex(repoSpec, metadata.Namespace, idx, gitDir)
+		if err != nil {
+			return status, err
+		}
+	}
 
 	name := status.IndexConfigMapName
 	if name == "" {
 		name = owner.Name
 	}
 
-	cm, err := r.createOrUpdateMap(metadata.Namespace, name, index, owner)
+	gzippedIndex, err := index.GzipIndex(idx)
 	if err != nil {
 		return status, err
 	}
 
-	status.IndexConfigMapName = cm.Name
-	status.IndexConfigMapNamespace = cm.Namespace
-	status.IndexConfigMapResourceVersion = cm.ResourceVersion
+	if err := r.indexStoreFor(len(gzippedIndex)).Save(metadata.Namespace, name, idx, gzippedIndex, owner, verifiedDigests, &status); err != nil {
+		return status, err
+	}
+
 	status.DownloadTime = downloadTime
 	status.Commit = commit
 	return status, nil
 }
 
+// verifyIndex checks every chart version in index against its .prov
+// signature. In VerifyModeRequired, any chart missing a valid signature
+// fails the whole download (surfaced through the RepoDownloaded condition
+// by the caller returning the error). In VerifyModeIfPresent, only charts
+// with a signature are checked, and their digests are returned for storage
+// on the catalog.cattle.io/verified-digests annotation; missing or invalid
+// signatures are otherwise ignored. gitDir, when non-empty, is the local
+// working tree to resolve chart/.prov siblings from instead of fetching
+// them over HTTP.
+func (r *repoHandler) verifyIndex(repoSpec *catalog.RepoSpec, namespace string, index *repo.IndexFile, gitDir string) (map[string]string, error) {
+	mode := verify.ModeFor(repoSpec.Verification)
+	keyringRef := repoSpec.Verification.Keyring
+	if keyringRef == nil {
+		return nil, fmt.Errorf("verification is enabled but no keyring secret is configured")
+	}
+
+	keyringNamespace := keyringRef.Namespace
+	if keyringNamespace == "" {
+		keyringNamespace = namespace
+	}
+	keyringSecret, err := r.secrets.Get(keyringNamespace, keyringRef.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	keyringPath, cleanupKeyring, err := verify.Keyring(keyringSecret)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanupKeyring()
+
+	client, err := verify.HTTPClient(repoSpec.CABundle, repoSpec.InsecureSkipTLSverify)
+	if err != nil {
+		return nil, err
+	}
+
+	digests := map[string]string{}
+	for chartName, versions := range index.Entries {
+		for _, version := range versions {
+			if len(version.URLs) == 0 {
+				continue
+			}
+
+			digest, err := verifyChartVersion(client, gitDir, version.URLs[0], keyringPath)
+			if err != nil {
+				if mode == catalog.VerifyModeRequired {
+					return nil, fmt.Errorf("chart %s-%s failed provenance verification: %w", chartName, version.Version, err)
+				}
+				continue
+			}
+
+			digests[chartName+"-"+version.Version] = digest
+		}
+	}
+
+	return digests, nil
+}
+
+// verifyChartVersion resolves the chart archive and its .prov sibling for a
+// single IndexFile entry, either from a local git working tree or by
+// fetching both over HTTP, and verifies the signature.
+func verifyChartVersion(client *http.Client, gitDir, chartURL, keyringPath string) (string, error) {
+	if gitDir != "" {
+		chartPath := filepath.Join(gitDir, chartURL)
+		return verify.Chart(chartPath, chartPath+".prov", keyringPath)
+	}
+
+	chartPath, cleanupChart, err := verify.Fetch(client, chartURL)
+	if err != nil {
+		return "", err
+	}
+	defer cleanupChart()
+
+	provPath, cleanupProv, err := verify.Fetch(client, chartURL+".prov")
+	if err != nil {
+		return "", err
+	}
+	defer cleanupProv()
+
+	return verify.Chart(chartPath, provPath, keyringPath)
+}
+
 func (r *repoHandler) ensureIndexConfigMap(repo *catalog.ClusterRepo, status *catalog.RepoStatus) error {
-	// Charts from the clusterRepo will be unavailable if the IndexConfigMap recorded in the status does not exist.
-	// By resetting the value of IndexConfigMapName, IndexConfigMapNamespace, IndexConfigMapResourceVersion to "",
-	// the method shouldRefresh will return true and trigger the rebuild of the IndexConfigMap and accordingly update the status.
+	// Charts from the clusterRepo will be unavailable if the index recorded
+	// in the status does not exist, whichever store holds it. By resetting
+	// the relevant status fields to "", the method shouldRefresh will
+	// return true and trigger the rebuild of the index and accordingly
+	// update the status.
 	if repo.Spec.GitRepo != "" && status.IndexConfigMapName != "" {
 		_, err := r.configMapCache.Get(status.IndexConfigMapNamespace, status.IndexConfigMapName)
 		if err != nil {
@@ -313,6 +438,16 @@ func (r *repoHandler) ensureIndexConfigMap(repo *catalog.ClusterRepo, status *ca
 			return err
 		}
 	}
+	if status.IndexDigest != "" {
+		if _, err := content.Index(r.loadIndexStoreFor(status), repo.Namespace, status); err != nil {
+			if apierrors.IsNotFound(err) {
+				status.IndexDigest = ""
+				status.IndexSize = 0
+				return nil
+			}
+			return err
+		}
+	}
 	return nil
 }
 
@@ -321,7 +456,7 @@ func shouldRefresh(spec *catalog.RepoSpec, status *catalog.RepoStatus) bool {
 	if spec.GitRepo != "" && status.Branch != spec.GitBranch {
 		return true
 	}
-	// repository URL changed for http(s) URL to an index generated by Helm
+	// repository URL changed for http(s) URL to an index generated by Helm, or for an oci:// registry URL
 	if spec.URL != "" && spec.URL != status.URL {
 		return true
 	}
@@ -329,14 +464,13 @@ func shouldRefresh(spec *catalog.RepoSpec, status *catalog.RepoStatus) bool {
 	if spec.GitRepo != "" && spec.GitRepo != status.URL {
 		return true
 	}
-	// configMap to be updated or created (holds chart versions)
-	if status.IndexConfigMapName == "" {
+	// index needs to be downloaded and stored for the first time
+	if status.IndexConfigMapName == "" && status.IndexDigest == "" {
 		return true
 	}
 	// forced update requested by user (refresh button)
 	if spec.ForceUpdate != nil && spec.ForceUpdate.After(status.DownloadTime.Time) && spec.ForceUpdate.Time.Before(time.Now()) {
 		return true
 	}
-	refreshTime := time.Now().Add(-interval)
-	return refreshTime.After(status.DownloadTime.Time)
+	return scheduleSpecFor(spec).Due(status.DownloadTime.Time)
 }
@@ -0,0 +1,174 @@
+// Package hardening runs CIS hardening against a set of test cluster nodes.
+// Hardening is expressed as a declarative Profile of idempotent Tasks rather
+// than a single hardcoded shell script, so that RKE2, RKE1 and K3s clusters
+// (and any future distro) can share the same runner while only differing in
+// which tasks they register. Before any task runs, the engine gathers a
+// Facts snapshot of each node over a single SSH session so Task.Check can
+// decide convergence without its own round trip, turning what used to be a
+// fire-and-forget shell loop into a convergent, re-runnable operation with
+// observable drift.
+package hardening
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/rancher/rancher/tests/framework/pkg/nodes"
+	"github.com/sirupsen/logrus"
+)
+
+// Task is a single idempotent hardening operation, such as writing a sysctl
+// value or installing a file. Check must be safe to call repeatedly and must
+// not mutate the node; Apply performs the actual change and describes it.
+type Task interface {
+	// Name identifies the task in reports and logs.
+	Name() string
+	// AppliesTo reports whether this task should run against a node that
+	// was given nodeRole (the raw role string from the test's node role
+	// list, e.g. "--etcd --controlplane").
+	AppliesTo(nodeRole string) bool
+	// Check reports whether the task's effect is already present on node,
+	// using the facts gathered for this run.
+	Check(node *nodes.Node, facts *Facts) (alreadyApplied bool, err error)
+	// Apply performs the task's operation against node and returns a short
+	// human-readable description of what changed.
+	Apply(node *nodes.Node) (diff string, err error)
+}
+
+// Profile is a named, ordered set of Tasks for a specific distro/CIS
+// benchmark combination, e.g. RKE2/CIS-1.7.
+type Profile interface {
+	Name() string
+	Tasks() []Task
+}
+
+// TaskOutcome records what happened for one task against one node.
+type TaskOutcome struct {
+	Task  string `json:"task"`
+	Diff  string `json:"diff,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// NodeReport summarizes what happened when a Profile's tasks ran against a
+// single node: which tasks changed state, which were already compliant
+// ("ok"), which did not apply to this node's role ("skipped"), and which
+// failed, plus a per-task diff for anything that changed.
+type NodeReport struct {
+	NodeID        string        `json:"nodeId"`
+	KernelVersion string        `json:"kernelVersion,omitempty"`
+	OSRelease     string        `json:"osRelease,omitempty"`
+	Changed       []TaskOutcome `json:"changed"`
+	OK            []string      `json:"ok"`
+	Skipped       []string      `json:"skipped"`
+	Failed        []TaskOutcome `json:"failed"`
+}
+
+// Counts returns the {changed, ok, skipped, failed} summary counts tests can
+// assert on without inspecting the full per-task detail.
+func (r NodeReport) Counts() map[string]int {
+	return map[string]int{
+		"changed": len(r.Changed),
+		"ok":      len(r.OK),
+		"skipped": len(r.Skipped),
+		"failed":  len(r.Failed),
+	}
+}
+
+// defaultWorkers bounds how many nodes are hardened concurrently when the
+// caller does not specify a worker count.
+const defaultWorkers = 5
+
+// Run gathers Facts for, then applies profile's tasks to, every node in
+// parallel using a worker pool bounded by workers (defaultWorkers if
+// workers <= 0), and returns one NodeReport per node. A per-node failure
+// does not stop hardening of the other nodes; inspect each NodeReport.Failed
+// to detect it.
+func Run(profile Profile, nodeList []*nodes.Node, nodeRoles []string, workers int) ([]NodeReport, error) {
+	if len(nodeList) != len(nodeRoles) {
+		return nil, fmt.Errorf("hardening: got %d nodes but %d node roles", len(nodeList), len(nodeRoles))
+	}
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	logrus.Infof("Starting to harden %d node(s) with profile %s", len(nodeList), profile.Name())
+
+	reports := make([]NodeReport, len(nodeList))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	for i := range nodeList {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			reports[i] = runNode(profile, nodeList[i], nodeRoles[i])
+		}(i)
+	}
+	wg.Wait()
+
+	return reports, nil
+}
+
+func runNode(profile Profile, node *nodes.Node, nodeRole string) NodeReport {
+	report := NodeReport{NodeID: node.NodeID}
+
+	tasks := applicableTasks(profile.Tasks(), nodeRole)
+	report.Skipped = skippedNames(profile.Tasks(), tasks)
+
+	facts, err := gatherFacts(node, tasks)
+	if err != nil {
+		for _, task := range tasks {
+			report.Failed = append(report.Failed, TaskOutcome{Task: task.Name(), Error: err.Error()})
+		}
+		return report
+	}
+	report.KernelVersion = facts.KernelVersion
+	report.OSRelease = facts.OSRelease
+
+	for _, task := range tasks {
+		alreadyApplied, err := task.Check(node, facts)
+		if err != nil {
+			report.Failed = append(report.Failed, TaskOutcome{Task: task.Name(), Error: fmt.Sprintf("checking: %s", err)})
+			continue
+		}
+		if alreadyApplied {
+			report.OK = append(report.OK, task.Name())
+			continue
+		}
+
+		diff, err := task.Apply(node)
+		if err != nil {
+			report.Failed = append(report.Failed, TaskOutcome{Task: task.Name(), Error: fmt.Sprintf("applying: %s", err)})
+			continue
+		}
+		report.Changed = append(report.Changed, TaskOutcome{Task: task.Name(), Diff: diff})
+	}
+
+	return report
+}
+
+func applicableTasks(tasks []Task, nodeRole string) []Task {
+	var out []Task
+	for _, task := range tasks {
+		if task.AppliesTo(nodeRole) {
+			out = append(out, task)
+		}
+	}
+	return out
+}
+
+func skippedNames(all, applicable []Task) []string {
+	applicableSet := map[string]bool{}
+	for _, task := range applicable {
+		applicableSet[task.Name()] = true
+	}
+	var skipped []string
+	for _, task := range all {
+		if !applicableSet[task.Name()] {
+			skipped = append(skipped, task.Name())
+		}
+	}
+	return skipped
+}
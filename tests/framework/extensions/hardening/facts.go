@@ -0,0 +1,185 @@
+package hardening
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/rancher/tests/framework/pkg/nodes"
+)
+
+// Facts is the node state gathered once, up front, via a single SSH
+// multiplexed session, so that every Task.Check in the run can decide
+// whether it needs to act without each issuing its own round trip.
+type Facts struct {
+	// SysctlValues holds the key=value pairs currently present in the
+	// sysctl file the run's SysctlTasks write to. Only one file is read:
+	// when tasks name different files, the last one declared is used.
+	SysctlValues map[string]string
+	// UserExists holds, for every username any EnsureUserTask in this run
+	// cares about, whether `getent passwd <user>` succeeded.
+	UserExists map[string]bool
+	// FileSHA256 holds the SHA-256 of every remote path any SCPFileTask in
+	// this run cares about; an empty string means the file is absent.
+	FileSHA256 map[string]string
+
+	KernelVersion string
+	OSRelease     string
+}
+
+// probeSpec is what a single Task asks the Facts phase to collect on its
+// behalf. Tasks that need Facts-driven Check implement `probe() probeSpec`.
+type probeSpec struct {
+	sysctlFile string
+	sysctlKeys []string
+	usernames  []string
+	files      []string
+}
+
+func mergeProbes(tasks []Task) probeSpec {
+	var merged probeSpec
+	for _, task := range tasks {
+		probing, ok := task.(interface{ probe() probeSpec })
+		if !ok {
+			continue
+		}
+		spec := probing.probe()
+		if spec.sysctlFile != "" {
+			merged.sysctlFile = spec.sysctlFile
+		}
+		merged.sysctlKeys = append(merged.sysctlKeys, spec.sysctlKeys...)
+		merged.usernames = append(merged.usernames, spec.usernames...)
+		merged.files = append(merged.files, spec.files...)
+	}
+	return merged
+}
+
+const (
+	markerSysctl = "===FACTS:SYSCTL==="
+	markerUsers  = "===FACTS:USERS==="
+	markerFiles  = "===FACTS:FILES==="
+	markerKernel = "===FACTS:KERNEL==="
+	markerOS     = "===FACTS:OSRELEASE==="
+)
+
+// gatherFacts runs every probe any task in tasks needs in a single remote
+// command and parses the result into a Facts struct.
+func gatherFacts(node *nodes.Node, tasks []Task) (*Facts, error) {
+	spec := mergeProbes(tasks)
+	out, err := node.ExecuteCommand(factsScript(spec))
+	if err != nil {
+		return nil, fmt.Errorf("gathering facts from node %s: %w", node.NodeID, err)
+	}
+	return parseFacts(out), nil
+}
+
+func factsScript(spec probeSpec) string {
+	var b strings.Builder
+	b.WriteString("sudo bash -c '")
+
+	fmt.Fprintf(&b, "echo %s; ", markerSysctl)
+	if spec.sysctlFile != "" {
+		fmt.Fprintf(&b, "cat %s 2>/dev/null; ", spec.sysctlFile)
+	}
+
+	fmt.Fprintf(&b, "echo %s; ", markerUsers)
+	for _, user := range dedupe(spec.usernames) {
+		fmt.Fprintf(&b, "getent passwd %s >/dev/null 2>&1 && echo -e \"%s\\t1\" || echo -e \"%s\\t0\"; ", user, user, user)
+	}
+
+	fmt.Fprintf(&b, "echo %s; ", markerFiles)
+	for _, file := range dedupe(spec.files) {
+		fmt.Fprintf(&b, "if [ -f %s ]; then echo -e \"%s\\t$(sha256sum %s | cut -d\" \" -f1)\"; else echo -e \"%s\\tMISSING\"; fi; ", file, file, file, file)
+	}
+
+	fmt.Fprintf(&b, "echo %s; uname -r; ", markerKernel)
+	fmt.Fprintf(&b, "echo %s; cat /etc/os-release; ", markerOS)
+
+	b.WriteString("'")
+	return b.String()
+}
+
+func dedupe(in []string) []string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(in))
+	for _, v := range in {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func parseFacts(output string) *Facts {
+	facts := &Facts{
+		SysctlValues: map[string]string{},
+		UserExists:   map[string]bool{},
+		FileSHA256:   map[string]string{},
+	}
+
+	section := ""
+	for _, line := range strings.Split(output, "\n") {
+		switch strings.TrimSpace(line) {
+		case markerSysctl:
+			section = "sysctl"
+			continue
+		case markerUsers:
+			section = "users"
+			continue
+		case markerFiles:
+			section = "files"
+			continue
+		case markerKernel:
+			section = "kernel"
+			continue
+		case markerOS:
+			section = "os"
+			continue
+		}
+
+		switch section {
+		case "sysctl":
+			if key, value, ok := strings.Cut(line, "="); ok && key != "" {
+				facts.SysctlValues[key] = value
+			}
+		case "users":
+			if user, exists, ok := strings.Cut(line, "\t"); ok {
+				facts.UserExists[user] = exists == "1"
+			}
+		case "files":
+			if file, digest, ok := strings.Cut(line, "\t"); ok {
+				if digest == "MISSING" {
+					digest = ""
+				}
+				facts.FileSHA256[file] = digest
+			}
+		case "kernel":
+			if strings.TrimSpace(line) != "" {
+				facts.KernelVersion = strings.TrimSpace(line)
+				section = ""
+			}
+		case "os":
+			if strings.HasPrefix(line, "PRETTY_NAME=") {
+				facts.OSRelease = strings.Trim(strings.TrimPrefix(line, "PRETTY_NAME="), `"`)
+			}
+		}
+	}
+
+	return facts
+}
+
+// fileChanged reports whether localPath's contents differ from the remote
+// digest Facts recorded for remotePath, treating an unknown remotePath (one
+// no SCPFileTask declared via probe()) as "changed" so Check falls back
+// safely to re-copying.
+func (f *Facts) fileChanged(remotePath, localSHA256 string) bool {
+	digest, known := f.FileSHA256[remotePath]
+	return !known || digest != localSHA256 || digest == ""
+}
+
+// sysctlSatisfied reports whether key is already set to value in the
+// gathered sysctl facts.
+func (f *Facts) sysctlSatisfied(key, value string) bool {
+	current, ok := f.SysctlValues[key]
+	return ok && current == value
+}
@@ -0,0 +1,120 @@
+package hardening
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFactsScriptUsesEchoDashEForUsersAndFiles(t *testing.T) {
+	script := factsScript(probeSpec{usernames: []string{"etcd"}, files: []string{"/etc/kubernetes/config"}})
+
+	for _, user := range []string{"etcd"} {
+		for _, want := range []string{
+			`getent passwd ` + user + ` >/dev/null 2>&1 && echo -e "` + user + `\t1" || echo -e "` + user + `\t0"`,
+		} {
+			if !strings.Contains(script, want) {
+				t.Fatalf("factsScript missing %q in:\n%s", want, script)
+			}
+		}
+	}
+
+	if strings.Contains(script, `echo "`+"etcd"+`\t1"`) {
+		t.Fatal("factsScript should not use echo without -e, since bash's builtin echo does not expand \\t without it")
+	}
+}
+
+func TestParseFactsUsers(t *testing.T) {
+	output := strings.Join([]string{
+		markerSysctl,
+		markerUsers,
+		"etcd\t1",
+		"nonexistent\t0",
+		markerFiles,
+		markerKernel,
+		"5.15.0-generic",
+		markerOS,
+		`PRETTY_NAME="Ubuntu 22.04.3 LTS"`,
+	}, "\n")
+
+	facts := parseFacts(output)
+
+	if !facts.UserExists["etcd"] {
+		t.Error("expected UserExists[etcd] to be true")
+	}
+	if facts.UserExists["nonexistent"] {
+		t.Error("expected UserExists[nonexistent] to be false")
+	}
+	if facts.KernelVersion != "5.15.0-generic" {
+		t.Errorf("KernelVersion = %q, want 5.15.0-generic", facts.KernelVersion)
+	}
+	if facts.OSRelease != "Ubuntu 22.04.3 LTS" {
+		t.Errorf("OSRelease = %q, want Ubuntu 22.04.3 LTS", facts.OSRelease)
+	}
+}
+
+func TestParseFactsUsersRequiresTabSeparator(t *testing.T) {
+	// This is what bash's echo (without -e) actually prints for "%s\t1" -
+	// the literal two characters backslash-t, not a tab. Guards against the
+	// users probe regressing to a plain echo again.
+	output := strings.Join([]string{
+		markerUsers,
+		`etcd\t1`,
+		markerFiles,
+	}, "\n")
+
+	facts := parseFacts(output)
+
+	if len(facts.UserExists) != 0 {
+		t.Errorf("expected no users parsed from a literal backslash-t line, got %v", facts.UserExists)
+	}
+}
+
+func TestParseFactsSysctlAndFiles(t *testing.T) {
+	output := strings.Join([]string{
+		markerSysctl,
+		"net.ipv4.ip_forward=1",
+		markerUsers,
+		markerFiles,
+		"/etc/kubernetes/config\tabc123",
+		"/etc/kubernetes/missing\tMISSING",
+	}, "\n")
+
+	facts := parseFacts(output)
+
+	if facts.SysctlValues["net.ipv4.ip_forward"] != "1" {
+		t.Errorf("SysctlValues[net.ipv4.ip_forward] = %q, want 1", facts.SysctlValues["net.ipv4.ip_forward"])
+	}
+	if facts.FileSHA256["/etc/kubernetes/config"] != "abc123" {
+		t.Errorf("FileSHA256[/etc/kubernetes/config] = %q, want abc123", facts.FileSHA256["/etc/kubernetes/config"])
+	}
+	if digest, known := facts.FileSHA256["/etc/kubernetes/missing"]; !known || digest != "" {
+		t.Errorf("FileSHA256[/etc/kubernetes/missing] = (%q, %v), want (\"\", true)", digest, known)
+	}
+}
+
+func TestFileChangedAndSysctlSatisfied(t *testing.T) {
+	facts := &Facts{
+		FileSHA256:   map[string]string{"/etc/known": "abc123"},
+		SysctlValues: map[string]string{"net.ipv4.ip_forward": "1"},
+	}
+
+	if facts.fileChanged("/etc/known", "abc123") {
+		t.Error("matching digest should not be reported as changed")
+	}
+	if !facts.fileChanged("/etc/known", "different") {
+		t.Error("mismatched digest should be reported as changed")
+	}
+	if !facts.fileChanged("/etc/unknown", "abc123") {
+		t.Error("a path with no gathered fact should be treated as changed")
+	}
+
+	if !facts.sysctlSatisfied("net.ipv4.ip_forward", "1") {
+		t.Error("matching sysctl value should be satisfied")
+	}
+	if facts.sysctlSatisfied("net.ipv4.ip_forward", "0") {
+		t.Error("mismatched sysctl value should not be satisfied")
+	}
+	if facts.sysctlSatisfied("net.ipv4.unknown", "1") {
+		t.Error("an unknown sysctl key should not be satisfied")
+	}
+}
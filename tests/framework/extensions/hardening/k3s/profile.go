@@ -0,0 +1,30 @@
+// Package k3s provides the hardening.Profile for K3s clusters following the
+// CIS 1.7 benchmark.
+package k3s
+
+import (
+	"github.com/rancher/rancher/tests/framework/extensions/hardening"
+)
+
+const sysctlFile = "/etc/sysctl.d/90-kubelet.conf"
+
+type profile struct {
+	tasks []hardening.Task
+}
+
+// Profile returns the K3s/CIS-1.7 hardening.Profile. K3s bundles its own
+// embedded etcd and does not run it as a separate system user, so unlike
+// RKE2/RKE1 this profile has no etcd-user task.
+func Profile() hardening.Profile {
+	return &profile{
+		tasks: []hardening.Task{
+			hardening.NewSysctlTask("vm.panic_on_oom", nil, sysctlFile, "vm.panic_on_oom", "0"),
+			hardening.NewSysctlTask("vm.overcommit_memory", nil, sysctlFile, "vm.overcommit_memory", "1"),
+			hardening.NewSysctlTask("kernel.panic", nil, sysctlFile, "kernel.panic", "10"),
+			hardening.NewSysctlTask("kernel.panic_on_oops", nil, sysctlFile, "kernel.panic_on_oops", "1"),
+		},
+	}
+}
+
+func (p *profile) Name() string           { return "K3s/CIS-1.7" }
+func (p *profile) Tasks() []hardening.Task { return p.tasks }
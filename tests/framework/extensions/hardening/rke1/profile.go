@@ -0,0 +1,45 @@
+// Package rke1 provides the hardening.Profile for RKE1 clusters following
+// the CIS 1.6 benchmark.
+package rke1
+
+import (
+	"github.com/rancher/rancher/tests/framework/extensions/hardening"
+)
+
+const (
+	sysctlFile = "/etc/sysctl.d/90-kubelet.conf"
+
+	// RKE1 has no account-update.sh equivalent; the kubelet CIS
+	// requirements are instead satisfied by a systemd drop-in.
+	kubeletDropInDir  = "/etc/systemd/system/kubelet.service.d"
+	kubeletDropInFile = kubeletDropInDir + "/10-cis.conf"
+)
+
+type profile struct {
+	tasks []hardening.Task
+}
+
+// Profile returns the RKE1/CIS-1.6 hardening.Profile: kernel sysctls and an
+// etcd service account, same as RKE2, plus a kubelet systemd drop-in instead
+// of RKE2's account-update.sh.
+func Profile() hardening.Profile {
+	return &profile{
+		tasks: []hardening.Task{
+			hardening.NewSysctlTask("vm.panic_on_oom", nil, sysctlFile, "vm.panic_on_oom", "0"),
+			hardening.NewSysctlTask("vm.overcommit_memory", nil, sysctlFile, "vm.overcommit_memory", "1"),
+			hardening.NewSysctlTask("kernel.panic", nil, sysctlFile, "kernel.panic", "10"),
+			hardening.NewSysctlTask("kernel.panic_on_oops", nil, sysctlFile, "kernel.panic_on_oops", "1"),
+
+			hardening.NewEnsureUserTask("etcd-user", []string{"--etcd"}, "etcd", "etcd user", "/sbin/nologin"),
+
+			hardening.NewRunScriptTask("kubelet-dropin", []string{"--controlplane", "--worker"},
+				"sudo test -f "+kubeletDropInFile,
+				"sudo bash -c 'mkdir -p "+kubeletDropInDir+
+					" && printf \"[Service]\\nEnvironment=PROTECT_KERNEL_DEFAULTS=true\\n\" > "+kubeletDropInFile+
+					" && systemctl daemon-reload'"),
+		},
+	}
+}
+
+func (p *profile) Name() string           { return "RKE1/CIS-1.6" }
+func (p *profile) Tasks() []hardening.Task { return p.tasks }
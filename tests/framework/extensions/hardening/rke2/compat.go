@@ -0,0 +1,16 @@
+package rke2
+
+import (
+	"github.com/rancher/rancher/tests/framework/clients/rancher"
+	"github.com/rancher/rancher/tests/framework/extensions/hardening"
+	"github.com/rancher/rancher/tests/framework/pkg/nodes"
+)
+
+// HardeningNodes is kept for existing callers and simply runs the
+// RKE2/CIS-1.7 Profile through the shared hardening engine. New test authors
+// should prefer calling hardening.Run directly with the profile (rke2,
+// rke1, or k3s) that matches the cluster under test.
+func HardeningNodes(client *rancher.Client, hardened bool, nodeList []*nodes.Node, nodeRoles []string) error {
+	_, err := hardening.Run(Profile(), nodeList, nodeRoles, 0)
+	return err
+}
@@ -0,0 +1,62 @@
+// Package rke2 provides the hardening.Profile for RKE2 clusters following
+// the CIS 1.7 benchmark.
+package rke2
+
+import (
+	"os/user"
+	"path/filepath"
+
+	"github.com/rancher/rancher/tests/framework/extensions/hardening"
+)
+
+const (
+	sysctlFile = "/etc/sysctl.d/90-kubelet.conf"
+
+	accountUpdateDir    = "/var/lib/rancher/rke2/server"
+	accountUpdateScript = accountUpdateDir + "/account-update.sh"
+	accountUpdateYAML   = accountUpdateDir + "/account-update.yaml"
+)
+
+// profile is the hardening.Profile for RKE2/CIS-1.7.
+type profile struct {
+	tasks []hardening.Task
+}
+
+// Profile returns the RKE2/CIS-1.7 hardening.Profile: kernel sysctls on
+// every node, an etcd service account on etcd nodes, and the RKE2
+// account-update script/role bindings on control plane nodes.
+func Profile() hardening.Profile {
+	dir := assetDir()
+
+	return &profile{
+		tasks: []hardening.Task{
+			hardening.NewSysctlTask("vm.panic_on_oom", nil, sysctlFile, "vm.panic_on_oom", "0"),
+			hardening.NewSysctlTask("vm.overcommit_memory", nil, sysctlFile, "vm.overcommit_memory", "1"),
+			hardening.NewSysctlTask("kernel.panic", nil, sysctlFile, "kernel.panic", "10"),
+			hardening.NewSysctlTask("kernel.panic_on_oops", nil, sysctlFile, "kernel.panic_on_oops", "1"),
+
+			hardening.NewEnsureUserTask("etcd-user", []string{"--etcd"}, "etcd", "etcd user", "/sbin/nologin"),
+
+			hardening.NewSCPFileTask("account-update-yaml", []string{"--controlplane"},
+				filepath.Join(dir, "account-update.yaml"), "account-update.yaml", accountUpdateYAML),
+			hardening.NewSCPFileTask("account-update-sh", []string{"--controlplane"},
+				filepath.Join(dir, "account-update.sh"), "account-update.sh", accountUpdateScript,
+				"sudo bash -c 'chmod +x "+accountUpdateScript+"'"),
+			hardening.NewRunScriptTask("run-account-update", []string{"--controlplane"}, "",
+				"sudo bash -c 'export KUBECONFIG=/etc/rancher/rke2/rke2.yaml && "+accountUpdateScript+"'"),
+		},
+	}
+}
+
+func (p *profile) Name() string           { return "RKE2/CIS-1.7" }
+func (p *profile) Tasks() []hardening.Task { return p.tasks }
+
+// assetDir mirrors the historical layout: account-update.yaml/.sh ship next
+// to this package in the test author's GOPATH checkout.
+func assetDir() string {
+	u, err := user.Current()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(u.HomeDir, "go/src/github.com/rancher/rancher/tests/framework/extensions/hardening/rke2")
+}
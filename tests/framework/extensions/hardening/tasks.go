@@ -0,0 +1,200 @@
+package hardening
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rancher/rancher/tests/framework/pkg/nodes"
+)
+
+// roleTask implements the AppliesTo behavior shared by all built-in tasks:
+// an empty roles list means "every node"; otherwise the node's role string
+// (e.g. "--etcd --controlplane") must contain at least one of roles.
+type roleTask struct {
+	roles []string
+}
+
+func (r roleTask) AppliesTo(nodeRole string) bool {
+	if len(r.roles) == 0 {
+		return true
+	}
+	for _, role := range r.roles {
+		if strings.Contains(nodeRole, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// SysctlTask ensures a single "key=value" line is present in sysctlFile and
+// reloads it with sysctl -p. It is idempotent: Check consults the Facts
+// phase's parsed sysctl values first, so re-running the hardening engine no
+// longer duplicates entries in the target file, and kernel parameters
+// already at the target value are skipped without a remote round trip.
+type SysctlTask struct {
+	roleTask
+	name       string
+	sysctlFile string
+	key, value string
+}
+
+// NewSysctlTask builds a Task that appends "key=value" to sysctlFile on
+// nodes matching roles (nil/empty applies to all nodes).
+func NewSysctlTask(name string, roles []string, sysctlFile, key, value string) *SysctlTask {
+	return &SysctlTask{roleTask: roleTask{roles: roles}, name: name, sysctlFile: sysctlFile, key: key, value: value}
+}
+
+func (t *SysctlTask) Name() string { return t.name }
+
+func (t *SysctlTask) line() string { return fmt.Sprintf("%s=%s", t.key, t.value) }
+
+func (t *SysctlTask) probe() probeSpec {
+	return probeSpec{sysctlFile: t.sysctlFile, sysctlKeys: []string{t.key}}
+}
+
+func (t *SysctlTask) Check(_ *nodes.Node, facts *Facts) (bool, error) {
+	return facts.sysctlSatisfied(t.key, t.value), nil
+}
+
+func (t *SysctlTask) Apply(node *nodes.Node) (string, error) {
+	if _, err := node.ExecuteCommand(fmt.Sprintf("sudo bash -c 'echo %s >> %s'", t.line(), t.sysctlFile)); err != nil {
+		return "", err
+	}
+	if _, err := node.ExecuteCommand(fmt.Sprintf("sudo bash -c 'sysctl -p %s'", t.sysctlFile)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("set %s in %s", t.line(), t.sysctlFile), nil
+}
+
+// EnsureUserTask creates a system user (e.g. the etcd service account) if it
+// does not already exist.
+type EnsureUserTask struct {
+	roleTask
+	name, username, comment, shell string
+}
+
+// NewEnsureUserTask builds a Task that creates username as a no-login system
+// user on nodes matching roles.
+func NewEnsureUserTask(name string, roles []string, username, comment, shell string) *EnsureUserTask {
+	return &EnsureUserTask{roleTask: roleTask{roles: roles}, name: name, username: username, comment: comment, shell: shell}
+}
+
+func (t *EnsureUserTask) Name() string { return t.name }
+
+func (t *EnsureUserTask) probe() probeSpec {
+	return probeSpec{usernames: []string{t.username}}
+}
+
+func (t *EnsureUserTask) Check(_ *nodes.Node, facts *Facts) (bool, error) {
+	return facts.UserExists[t.username], nil
+}
+
+func (t *EnsureUserTask) Apply(node *nodes.Node) (string, error) {
+	if _, err := node.ExecuteCommand(fmt.Sprintf("sudo useradd -r -c %q -s %s -M %s -U", t.comment, t.shell, t.username)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("created user %s", t.username), nil
+}
+
+// SCPFileTask copies localPath into the node's SSH user's home directory and
+// moves it into finalPath, running any postMove commands (e.g. chmod +x)
+// afterward. Check compares the Facts phase's remote SHA-256 for finalPath
+// against localPath's own digest, so the copy is skipped once they match.
+type SCPFileTask struct {
+	roleTask
+	name                  string
+	localPath, remoteName string
+	finalPath             string
+	postMove              []string
+}
+
+// NewSCPFileTask builds a Task that copies localPath to
+// /home/<node's SSH user>/remoteName and moves it to finalPath on nodes
+// matching roles.
+func NewSCPFileTask(name string, roles []string, localPath, remoteName, finalPath string, postMove ...string) *SCPFileTask {
+	return &SCPFileTask{
+		roleTask:   roleTask{roles: roles},
+		name:       name,
+		localPath:  localPath,
+		remoteName: remoteName,
+		finalPath:  finalPath,
+		postMove:   postMove,
+	}
+}
+
+func (t *SCPFileTask) Name() string { return t.name }
+
+func (t *SCPFileTask) probe() probeSpec {
+	return probeSpec{files: []string{t.finalPath}}
+}
+
+func (t *SCPFileTask) Check(_ *nodes.Node, facts *Facts) (bool, error) {
+	localSHA256, err := sha256File(t.localPath)
+	if err != nil {
+		return false, err
+	}
+	return !facts.fileChanged(t.finalPath, localSHA256), nil
+}
+
+func (t *SCPFileTask) Apply(node *nodes.Node) (string, error) {
+	remoteTmpPath := fmt.Sprintf("/home/%s/%s", node.SSHUser, t.remoteName)
+	if err := node.SCPFileToNode(t.localPath, remoteTmpPath); err != nil {
+		return "", err
+	}
+	if _, err := node.ExecuteCommand(fmt.Sprintf("sudo bash -c 'mv %s %s'", remoteTmpPath, t.finalPath)); err != nil {
+		return "", err
+	}
+	for _, cmd := range t.postMove {
+		if _, err := node.ExecuteCommand(cmd); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("copied %s to %s", t.localPath, t.finalPath), nil
+}
+
+func sha256File(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// RunScriptTask runs an arbitrary remote command. When checkCmd is set, it
+// is run directly against the node (it has no Facts equivalent) and a nil
+// error is treated as "already applied"; leave it empty for operations,
+// such as one-shot scripts, that have no cheap way to detect prior
+// application.
+type RunScriptTask struct {
+	roleTask
+	name, checkCmd, runCmd string
+}
+
+// NewRunScriptTask builds a Task that runs runCmd on nodes matching roles,
+// optionally skipping it when checkCmd succeeds.
+func NewRunScriptTask(name string, roles []string, checkCmd, runCmd string) *RunScriptTask {
+	return &RunScriptTask{roleTask: roleTask{roles: roles}, name: name, checkCmd: checkCmd, runCmd: runCmd}
+}
+
+func (t *RunScriptTask) Name() string { return t.name }
+
+func (t *RunScriptTask) Check(node *nodes.Node, _ *Facts) (bool, error) {
+	if t.checkCmd == "" {
+		return false, nil
+	}
+	if _, err := node.ExecuteCommand(t.checkCmd); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (t *RunScriptTask) Apply(node *nodes.Node) (string, error) {
+	if _, err := node.ExecuteCommand(t.runCmd); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("ran %s", t.name), nil
+}